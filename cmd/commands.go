@@ -0,0 +1,700 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"go-sm-parser/parser"
+	_ "modernc.org/sqlite"
+)
+
+// outputFormats are the formats convert can write. json and proto write a file per simfile, or
+// a stream to stdout: NDJSON, and length-delimited messages for proto. The NDJSON formats always
+// stream to stdout, and csv and parquet write charts.<format>, and notes.<format> with -notes,
+// to the output directory. ucs writes a file per pump chart.
+var outputFormats = []string{"json", "proto", "ndjson", "ndjson-charts", "csv", "parquet", "ucs"}
+
+// newFlags returns the flag set of a command, printing its usage to stderr.
+func newFlags(name string, args string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: smparser %s [flags] %s\n", name, args)
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFlags parses the flags of a command, returning false and an exit code when the
+// command should not run.
+func parseFlags(flags *flag.FlagSet, args []string) (bool, int) {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return false, exitOK
+		}
+		return false, exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return false, exitUsage
+	}
+	return true, exitOK
+}
+
+// outputFlags are the flags of the commands writing files.
+type outputFlags struct {
+	dir       *string
+	toStdout  *bool
+	template  *string
+	mirror    *string
+	collision *string
+}
+
+// addOutputFlags defines the output flags of a command.
+func addOutputFlags(flags *flag.FlagSet) outputFlags {
+	return outputFlags{
+		dir:       flags.String("o", ".", "write files to this directory"),
+		toStdout:  flags.Bool("stdout", false, "write to stdout instead of files, one document per line"),
+		template:  flags.String("name", parser.DefaultTemplate, "file name template using {pack}, {song}, {file}, {title}, {artist}, {hash} and {difficulty}"),
+		mirror:    flags.String("mirror", "", "keep the directory structure below this input root"),
+		collision: flags.String("collision", "suffix", "when names collide: suffix, overwrite or error"),
+	}
+}
+
+// collisions maps the -collision flag to the collision policies.
+var collisions = map[string]parser.Collision{
+	"suffix":    parser.CollisionSuffix,
+	"overwrite": parser.CollisionOverwrite,
+	"error":     parser.CollisionError,
+}
+
+// writer returns the JSONWriter configured by the flags, or nil when writing to stdout.
+func (o outputFlags) writer() (*parser.JSONWriter, error) {
+	if *o.toStdout {
+		return nil, nil
+	}
+	collision, ok := collisions[*o.collision]
+	if !ok {
+		return nil, fmt.Errorf("unknown collision policy %q", *o.collision)
+	}
+	writer := parser.NewJSONWriter(*o.dir)
+	writer.Template, writer.Mirror, writer.Collision = *o.template, *o.mirror, collision
+	return writer, nil
+}
+
+// runParse parses simfiles and writes them as JSON.
+func runParse(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlags("parse", "<inputs>", stderr)
+	output := addOutputFlags(flags)
+	recursive := flags.Bool("r", false, "search directories recursively")
+	headerOnly := flags.Bool("header-only", false, "skip decoding note data")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	writer, err := output.writer()
+	if err != nil {
+		fmt.Fprintf(stderr, "smparser: %v\n", err)
+		return exitUsage
+	}
+	stream := parser.NewNDJSONWriter(stdout).Write
+	return convert(flags.Args(), writer, stream, *recursive, parser.ParseOptions{HeaderOnly: *headerOnly}, stderr)
+}
+
+// runConvert parses simfiles and writes them in the chosen format.
+func runConvert(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlags("convert", "<inputs>", stderr)
+	format := flags.String("format", "json", "output format: "+strings.Join(outputFormats, ", "))
+	output := addOutputFlags(flags)
+	recursive := flags.Bool("r", false, "search directories recursively")
+	notes := flags.Bool("notes", false, "with -format csv or parquet, also write a notes table")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if !isOutputFormat(*format) {
+		fmt.Fprintf(stderr, "smparser: unknown format %q\n", *format)
+		return exitUsage
+	}
+	switch *format {
+	case "ndjson", "ndjson-charts":
+		return streamNDJSON(flags.Args(), *format == "ndjson-charts", *recursive, stdout, stderr)
+	case "csv", "parquet":
+		return streamTables(flags.Args(), *format, *notes, *output.dir, *output.toStdout, *recursive, stdout, stderr)
+	}
+	writer, err := output.writer()
+	if err != nil {
+		fmt.Fprintf(stderr, "smparser: %v\n", err)
+		return exitUsage
+	}
+	if *format == "ucs" {
+		if writer == nil {
+			fmt.Fprintln(stderr, "smparser: -format ucs writes files and cannot write to stdout")
+			return exitUsage
+		}
+		if *output.template == parser.DefaultTemplate {
+			writer.Template = ucsTemplate
+		}
+		return convert(flags.Args(), nil, writeUCSCharts(writer), *recursive, parser.ParseOptions{}, stderr)
+	}
+	stream := parser.NewNDJSONWriter(stdout).Write
+	if *format == "proto" {
+		stream = func(sim parser.Simfile, source string) error { return parser.WriteProtoDelimited(stdout, sim) }
+		if writer != nil {
+			writer.Marshal, writer.Extension = parser.MarshalProto, ".pb"
+		}
+	}
+	return convert(flags.Args(), writer, stream, *recursive, parser.ParseOptions{}, stderr)
+}
+
+// ucsTemplate names .ucs files when -name is not given, since a song has a file per chart.
+const ucsTemplate = "{title} {difficulty}"
+
+// writeUCSCharts returns a stream writing each pump chart of a simfile to its own .ucs file
+// with writer.
+func writeUCSCharts(writer *parser.JSONWriter) func(sim parser.Simfile, source string) error {
+	writer.Marshal = func(sim parser.Simfile) ([]byte, error) { return parser.MarshalUCS(sim, 0) }
+	writer.Extension = ".ucs"
+	return func(sim parser.Simfile, source string) error {
+		written := false
+		for _, chart := range sim.Charts {
+			if !strings.HasPrefix(chart.Type, "pump-") {
+				continue
+			}
+			single := sim
+			single.Charts = []parser.Chart{chart}
+			if _, err := writer.Write(single, source); err != nil {
+				return err
+			}
+			written = true
+		}
+		if !written {
+			return errors.New("no pump-single or pump-double charts to write as UCS")
+		}
+		return nil
+	}
+}
+
+// isOutputFormat reports whether convert can write a format.
+func isOutputFormat(format string) bool {
+	for _, f := range outputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// convert parses the inputs and writes each simfile to a file with writer, or with stream when
+// writer is nil.
+func convert(inputs []string, writer *parser.JSONWriter, stream func(sim parser.Simfile, source string) error, recursive bool, options parser.ParseOptions, stderr io.Writer) int {
+	results, ok := parseInputs(inputs, recursive, options, stderr)
+	for _, result := range results {
+		var err error
+		if writer == nil {
+			err = stream(result.Simfile, result.Path)
+		} else {
+			_, err = writer.Write(result.Simfile, result.Path)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "smparser: %s: %v\n", result.Path, err)
+			ok = false
+		}
+	}
+	if !ok {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// streamNDJSON parses the inputs and writes them to stdout as NDJSON as they finish parsing.
+func streamNDJSON(inputs []string, charts bool, recursive bool, stdout io.Writer, stderr io.Writer) int {
+	out := bufio.NewWriter(stdout)
+	writer := parser.NewNDJSONWriter(out)
+	writer.Charts = charts
+	code := streamInputs(inputs, recursive, writer.Write, stderr)
+	if err := out.Flush(); err != nil {
+		fmt.Fprintf(stderr, "smparser: %v\n", err)
+		return exitFailure
+	}
+	return code
+}
+
+// streamTables parses the inputs and writes their charts, and optionally notes, as CSV or
+// Parquet tables to charts.<format> and notes.<format> in outDir, or the charts to stdout.
+func streamTables(inputs []string, format string, notes bool, outDir string, toStdout bool, recursive bool, stdout io.Writer, stderr io.Writer) int {
+	if notes && toStdout {
+		fmt.Fprintln(stderr, "smparser: -notes writes a second table and cannot be used with -stdout")
+		return exitUsage
+	}
+	chartsOut, notesOut := stdout, io.Writer(nil)
+	files := []*atomicFile{}
+	if !toStdout {
+		names := []string{"charts." + format}
+		if notes {
+			names = append(names, "notes."+format)
+		}
+		if err := os.MkdirAll(outDir, 0755); err != nil {
+			fmt.Fprintf(stderr, "smparser: %v\n", err)
+			return exitFailure
+		}
+		for _, name := range names {
+			file, err := createAtomic(filepath.Join(outDir, name))
+			if err != nil {
+				fmt.Fprintf(stderr, "smparser: %v\n", err)
+				return exitFailure
+			}
+			defer file.discard()
+			files = append(files, file)
+		}
+		chartsOut = files[0]
+		if notes {
+			notesOut = files[1]
+		}
+	}
+
+	var write func(sim parser.Simfile, source string) error
+	var finish func() error
+	if format == "parquet" {
+		writer := parser.NewParquetWriter(chartsOut, notesOut)
+		write, finish = writer.Write, writer.Close
+	} else {
+		writer := parser.NewCSVWriter(chartsOut, notesOut)
+		write, finish = writer.Write, writer.Flush
+	}
+	code := streamInputs(inputs, recursive, write, stderr)
+	if err := finish(); err != nil {
+		fmt.Fprintf(stderr, "smparser: %v\n", err)
+		return exitFailure
+	}
+	for _, file := range files {
+		if err := file.commit(); err != nil {
+			fmt.Fprintf(stderr, "smparser: %v\n", err)
+			return exitFailure
+		}
+	}
+	return code
+}
+
+// atomicFile is written under a temporary name and renamed into place by commit, like the
+// files of parser.JSONWriter, so a failed run does not leave a truncated table behind.
+type atomicFile struct {
+	*os.File
+	name string
+}
+
+// createAtomic creates the temporary file of name in the same directory.
+func createAtomic(name string) (*atomicFile, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{File: tmp, name: name}, nil
+}
+
+// commit closes the file, reporting write errors, and renames it to its name.
+func (f *atomicFile) commit() error {
+	if err := f.Chmod(0644); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.File.Name(), f.name)
+}
+
+// discard closes and removes the temporary file if commit did not rename it.
+func (f *atomicFile) discard() {
+	f.Close()
+	os.Remove(f.File.Name())
+}
+
+// streamInputs parses the inputs and calls write for each simfile in order as they finish
+// parsing, reporting failed files on stderr. JSON inputs are read back with ReadJSON, like in
+// parseInputs. It returns the exit code.
+func streamInputs(inputs []string, recursive bool, write func(sim parser.Simfile, source string) error, stderr io.Writer) int {
+	paths, err := expandInputs(inputs, recursive)
+	if err != nil {
+		fmt.Fprintf(stderr, "smparser: %v\n", err)
+		return exitFailure
+	}
+
+	simfiles := []string{}
+	for _, path := range paths {
+		if !isJSON(path) {
+			simfiles = append(simfiles, path)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	parsed := parser.ParseBatch(ctx, simfiles, parser.BatchOptions{Ordered: true})
+
+	code := exitOK
+	for _, path := range paths {
+		var result parser.BatchResult
+		if isJSON(path) {
+			result.Path = path
+			result.Simfile, result.Err = parser.ReadJSON(path)
+		} else {
+			var ok bool
+			if result, ok = <-parsed; !ok {
+				break
+			}
+		}
+		if result.Err != nil {
+			fmt.Fprintf(stderr, "smparser: %s: %v\n", result.Path, result.Err)
+			code = exitFailure
+			continue
+		}
+		if err := write(result.Simfile, result.Path); err != nil {
+			fmt.Fprintf(stderr, "smparser: %v\n", err)
+			return exitFailure
+		}
+	}
+	return code
+}
+
+// chartStats is the summary of a chart printed by stats.
+type chartStats struct {
+	Path       string          `json:"path"`
+	Title      string          `json:"title"`
+	Difficulty string          `json:"difficulty"`
+	Meter      int             `json:"meter"`
+	Notes      int             `json:"notes"`
+	Analysis   parser.Analysis `json:"analysis"`
+}
+
+// runStats prints the analysis of each dance-single chart.
+func runStats(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlags("stats", "<inputs>", stderr)
+	format := flags.String("format", "text", "output format: text, json")
+	recursive := flags.Bool("r", false, "search directories recursively")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "smparser: unknown format %q\n", *format)
+		return exitUsage
+	}
+
+	results, ok := parseInputs(flags.Args(), *recursive, parser.ParseOptions{}, stderr)
+	stats := []chartStats{}
+	for _, result := range results {
+		for _, chart := range result.Simfile.Charts {
+			if chart.Type != "dance-single" {
+				continue
+			}
+			notes := 0
+			for range parser.Rows(chart, result.Simfile.Header, parser.Hits) {
+				notes++
+			}
+			stats = append(stats, chartStats{
+				Path:       result.Path,
+				Title:      result.Simfile.Header.Title,
+				Difficulty: chart.Difficulty,
+				Meter:      chart.Meter,
+				Notes:      notes,
+				Analysis:   chart.Analysis,
+			})
+		}
+	}
+
+	if *format == "json" {
+		if err := writeIndented(stdout, stats); err != nil {
+			fmt.Fprintf(stderr, "smparser: %v\n", err)
+			return exitFailure
+		}
+	} else {
+		table := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(table, "TITLE\tDIFFICULTY\tMETER\tNOTES\tPEAK NPS\tESTIMATE\tCROSSOVERS")
+		for _, s := range stats {
+			fmt.Fprintf(table, "%s\t%s\t%d\t%d\t%.2f\t%.2f\t%d\n", s.Title, s.Difficulty, s.Meter, s.Notes,
+				s.Analysis.Density.PeakNPS, s.Analysis.Difficulty.Overall, s.Analysis.Parity.Crossovers)
+		}
+		table.Flush()
+	}
+	if !ok {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runValidate checks simfiles for parse errors and missing data.
+func runValidate(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlags("validate", "<inputs>", stderr)
+	recursive := flags.Bool("r", false, "search directories recursively")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+
+	results, ok := parseInputs(flags.Args(), *recursive, parser.ParseOptions{}, stderr)
+	for _, result := range results {
+		for _, problem := range problems(result.Simfile) {
+			fmt.Fprintf(stdout, "%s: %s\n", result.Path, problem)
+			ok = false
+		}
+	}
+	if !ok {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// problems lists the missing or invalid data of a parsed simfile.
+func problems(sim parser.Simfile) []string {
+	found := []string{}
+	if sim.Header.Title == "" {
+		found = append(found, "missing #TITLE")
+	}
+	if len(sim.Header.BPMs) == 0 {
+		found = append(found, "missing #BPMS")
+	}
+	for _, bpm := range sim.Header.BPMs {
+		if bpm.Value == 0 {
+			found = append(found, fmt.Sprintf("zero BPM at beat %g", bpm.Beat))
+		}
+	}
+	if len(sim.Charts) == 0 {
+		found = append(found, "no charts")
+	}
+
+	seen := map[string]bool{}
+	for _, chart := range sim.Charts {
+		if chart.Type != "dance-single" {
+			continue
+		}
+		if seen[chart.Difficulty] {
+			found = append(found, fmt.Sprintf("duplicate %s chart", chart.Difficulty))
+		}
+		seen[chart.Difficulty] = true
+		if len(chart.Notes) == 0 {
+			found = append(found, fmt.Sprintf("%s chart has no notes", chart.Difficulty))
+		}
+	}
+	return found
+}
+
+// chartInfo describes a chart printed by info.
+type chartInfo struct {
+	Type        string `json:"type"`
+	Difficulty  string `json:"difficulty"`
+	Meter       int    `json:"meter"`
+	Description string `json:"description"`
+}
+
+// songInfo describes a simfile printed by info.
+type songInfo struct {
+	Path     string        `json:"path"`
+	SongPack string        `json:"song_pack"`
+	Header   parser.Header `json:"header"`
+	Charts   []chartInfo   `json:"charts"`
+}
+
+// runInfo prints the header and charts of simfiles without decoding their notes.
+func runInfo(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlags("info", "<inputs>", stderr)
+	format := flags.String("format", "text", "output format: text, json")
+	recursive := flags.Bool("r", false, "search directories recursively")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(stderr, "smparser: unknown format %q\n", *format)
+		return exitUsage
+	}
+
+	results, ok := parseInputs(flags.Args(), *recursive, parser.ParseOptions{HeaderOnly: true}, stderr)
+	songs := []songInfo{}
+	for _, result := range results {
+		song := songInfo{Path: result.Path, SongPack: result.Simfile.SongPack, Header: result.Simfile.Header, Charts: []chartInfo{}}
+		for _, chart := range result.Simfile.Charts {
+			if chart.Type == "" {
+				continue
+			}
+			song.Charts = append(song.Charts, chartInfo{chart.Type, chart.Difficulty, chart.Meter, chart.Description})
+		}
+		songs = append(songs, song)
+	}
+
+	if *format == "json" {
+		if err := writeIndented(stdout, songs); err != nil {
+			fmt.Fprintf(stderr, "smparser: %v\n", err)
+			return exitFailure
+		}
+	} else {
+		for _, song := range songs {
+			header := song.Header
+			fmt.Fprintln(stdout, song.Path)
+			fmt.Fprintf(stdout, "  Title:  %s\n", strings.TrimSpace(header.Title+" "+header.Subtitle))
+			fmt.Fprintf(stdout, "  Artist: %s\n", header.Artist)
+			fmt.Fprintf(stdout, "  Pack:   %s\n", song.SongPack)
+			fmt.Fprintf(stdout, "  BPM:    %s\n", bpmRange(header.BPMs))
+			for _, chart := range song.Charts {
+				fmt.Fprintf(stdout, "  %-14s %-10s %d\n", chart.Type, chart.Difficulty, chart.Meter)
+			}
+		}
+	}
+	if !ok {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// bpmRange formats the lowest and highest BPM of a song.
+//
+// Raw => [{0 120} {64 180}]
+// Parsed => 120-180
+func bpmRange(bpms []parser.BeatChange) string {
+	low, high := parser.BPMRange(bpms)
+	switch {
+	case high == 0:
+		return "?"
+	case low == high:
+		return fmt.Sprintf("%g", low)
+	default:
+		return fmt.Sprintf("%g-%g", low, high)
+	}
+}
+
+// runScan lists the packs and songs of library directories and zip archives.
+func runScan(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlags("scan", "<library directory or zip>...", stderr)
+	format := flags.String("format", "text", "output format: text, json, ndjson")
+	workers := flags.Int("workers", 0, "number of files parsed at once (default: number of CPUs)")
+	notes := flags.Bool("notes", false, "decode and analyze note data")
+	database := flags.String("sqlite", "", "export the libraries into this SQLite database, decoding notes")
+	cacheDir := flags.String("cache", "", "cache parsed songs in this directory, so rescans only parse changed files")
+	if ok, code := parseFlags(flags, args); !ok {
+		return code
+	}
+	if *format != "text" && *format != "json" && *format != "ndjson" {
+		fmt.Fprintf(stderr, "smparser: unknown format %q\n", *format)
+		return exitUsage
+	}
+
+	var cache *parser.Cache
+	if *cacheDir != "" {
+		var err error
+		if cache, err = parser.NewCache(*cacheDir); err != nil {
+			fmt.Fprintf(stderr, "smparser: %v\n", err)
+			return exitFailure
+		}
+	}
+
+	var db *sql.DB
+	if *database != "" {
+		var err error
+		if db, err = sql.Open("sqlite", *database); err != nil {
+			fmt.Fprintf(stderr, "smparser: %v\n", err)
+			return exitFailure
+		}
+		defer db.Close()
+	}
+
+	options := parser.BatchOptions{Workers: *workers, Cache: cache, Parse: parser.ParseOptions{HeaderOnly: !*notes && db == nil}}
+	libraries := []parser.Library{}
+	ok := true
+	for _, root := range flags.Args() {
+		scan := parser.ScanLibrary
+		if strings.EqualFold(filepath.Ext(root), ".zip") {
+			scan = parser.ScanZip
+		}
+		library, err := scan(context.Background(), root, options)
+		if err != nil {
+			fmt.Fprintf(stderr, "smparser: %s: %v\n", root, err)
+			ok = false
+			continue
+		}
+		for _, songErr := range library.Errors {
+			fmt.Fprintf(stderr, "smparser: %v\n", songErr)
+			ok = false
+		}
+		libraries = append(libraries, library)
+	}
+
+	if db != nil {
+		for _, library := range libraries {
+			export, err := parser.ExportSQLite(context.Background(), db, library)
+			if err != nil {
+				fmt.Fprintf(stderr, "smparser: %s: %v\n", *database, err)
+				return exitFailure
+			}
+			fmt.Fprintf(stdout, "%s: %d inserted, %d updated, %d unchanged, %d deleted\n",
+				library.Root, export.Inserted, export.Updated, export.Unchanged, export.Deleted)
+		}
+		if !ok {
+			return exitFailure
+		}
+		return exitOK
+	}
+
+	switch *format {
+	case "json":
+		if err := writeIndented(stdout, libraries); err != nil {
+			fmt.Fprintf(stderr, "smparser: %v\n", err)
+			return exitFailure
+		}
+	case "ndjson":
+		out := bufio.NewWriter(stdout)
+		writer := parser.NewNDJSONWriter(out)
+		for _, library := range libraries {
+			if err := writer.WriteLibrary(library); err != nil {
+				fmt.Fprintf(stderr, "smparser: %v\n", err)
+				return exitFailure
+			}
+		}
+		if err := out.Flush(); err != nil {
+			fmt.Fprintf(stderr, "smparser: %v\n", err)
+			return exitFailure
+		}
+	default:
+		for _, library := range libraries {
+			for _, pack := range library.Packs {
+				fmt.Fprintf(stdout, "%s (%d songs)\n", pack.Name, len(pack.Songs))
+				for _, song := range pack.Songs {
+					fmt.Fprintf(stdout, "  %s\n", song.Simfile.Header.Title)
+				}
+			}
+		}
+	}
+	if !ok {
+		return exitFailure
+	}
+	return exitOK
+}
+
+// runSchema prints the JSON Schema document of the JSON output.
+func runSchema(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := newFlags("schema", "", stderr)
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+	schema, err := parser.JSONSchema()
+	if err != nil {
+		fmt.Fprintf(stderr, "smparser: %v\n", err)
+		return exitFailure
+	}
+	if _, err := stdout.Write(schema); err != nil {
+		fmt.Fprintf(stderr, "smparser: %v\n", err)
+		return exitFailure
+	}
+	return exitOK
+}
+
+// writeIndented writes a value as indented JSON.
+func writeIndented(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
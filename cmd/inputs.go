@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go-sm-parser/parser"
+)
+
+// expandInputs resolves files, glob patterns, and directories to a sorted list of files.
+// Directories are searched for .sm, .dwi, .ksf and .ucs files.
+func expandInputs(args []string, recursive bool) ([]string, error) {
+	paths := []string{}
+	for _, arg := range args {
+		matches := []string{arg}
+		if strings.ContainsAny(arg, "*?[") {
+			var err error
+			matches, err = filepath.Glob(arg)
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %s", arg)
+			}
+		}
+		for _, match := range matches {
+			found, err := simfilesIn(match, recursive)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, found...)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no simfiles found")
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// simfilesIn returns the path itself for a file, or the simfile of each song folder in a
+// directory, picked with the same precedence as the library scanner.
+func simfilesIn(path string, recursive bool) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	dirs := []string{}
+	names := map[string][]string{}
+	err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != path && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		dir := filepath.Dir(p)
+		if _, ok := names[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		names[dir] = append(names[dir], d.Name())
+		return nil
+	})
+
+	paths := []string{}
+	for _, dir := range dirs {
+		if name := parser.SimfileName(names[dir]); name != "" {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	return paths, err
+}
+
+// parseInputs expands and parses the inputs, reporting failed files on stderr. Files named
+// explicitly may also be JSON written by parse, which is read back with ReadJSON.
+//
+// It returns the parsed files and whether every file parsed.
+func parseInputs(args []string, recursive bool, options parser.ParseOptions, stderr io.Writer) ([]parser.BatchResult, bool) {
+	paths, err := expandInputs(args, recursive)
+	if err != nil {
+		fmt.Fprintf(stderr, "smparser: %v\n", err)
+		return nil, false
+	}
+
+	simfiles := []string{}
+	for _, path := range paths {
+		if !isJSON(path) {
+			simfiles = append(simfiles, path)
+		}
+	}
+	parsed, err := parser.ParseAll(context.Background(), simfiles, parser.BatchOptions{Parse: options})
+	if err != nil {
+		fmt.Fprintf(stderr, "smparser: %v\n", err)
+		return nil, false
+	}
+
+	results := []parser.BatchResult{}
+	ok := true
+	for i, path := range paths {
+		result := parser.BatchResult{Index: i, Path: path}
+		if isJSON(path) {
+			result.Simfile, result.Err = parser.ReadJSON(path)
+		} else {
+			result, parsed = parsed[0], parsed[1:]
+			result.Index = i
+		}
+		if result.Err != nil {
+			fmt.Fprintf(stderr, "smparser: %s: %v\n", result.Path, result.Err)
+			ok = false
+			continue
+		}
+		results = append(results, result)
+	}
+	return results, ok
+}
+
+// isJSON reports whether a path names a JSON file.
+func isJSON(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".json")
+}
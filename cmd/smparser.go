@@ -1,36 +1,83 @@
 // Package main implements a Stepmania Simfile parser.
-// It currently supports the following format: sm
+// It currently supports the following formats: sm, dwi, ksf, ucs
+//
+// Usage:
+//
+//	smparser <command> [flags] <inputs>
+//
+// Inputs are .sm, .dwi, .ksf or .ucs files, glob patterns, or directories, which are searched for
+// simfiles (recursively with -r). A .ksf file stands for all the .ksf charts of its song folder.
 package main
 
 import (
-	"go-sm-parser/parser"
+	"fmt"
+	"io"
 	"os"
-	"strings"
+
+	"go-sm-parser/parser"
+)
+
+// Exit codes.
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
 )
 
+// command is a smparser subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string, stdout io.Writer, stderr io.Writer) int
+}
+
+var commands = []command{
+	{"parse", "parse simfiles and write them as JSON", runParse},
+	{"convert", "parse simfiles and write them in another format", runConvert},
+	{"stats", "print the analysis of each chart", runStats},
+	{"validate", "check simfiles for errors", runValidate},
+	{"info", "print the header and charts of simfiles", runInfo},
+	{"scan", "list the packs and songs of a library directory or zip", runScan},
+	{"schema", "print the JSON Schema of the JSON output", runSchema},
+}
+
 func main() {
-	// Read the simfile data into memory.
-	sim := parser.Simfile{}
-	smPath := os.Args[1]
-	data, err := parser.ReadSM(smPath)
-	parser.CheckError(err)
-
-	// Parse the header tags.
-	tags := strings.Split(string(data), ";")
-	sim.SongPack = parser.PackName(smPath)
-	for i := range tags {
-		tag := tags[i]
-		sim = parser.ExtractHeader(tag, sim)
-	}
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
 
-	// Parse the notes tag (chart data).
-	for i := range sim.Charts {
-		notes := parser.RawNoteValue(sim.Charts[i].RawData)
-		sim = parser.ExtractCharts(i, notes, sim)
+// run runs the command named by the first argument and returns the exit code.
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+	name := args[0]
+	if name == "help" || name == "-h" || name == "-help" || name == "--help" {
+		usage(stdout)
+		return exitOK
 	}
+	// Keep the original "smparser file.sm" form working.
+	if parser.Parsable(name) {
+		return runParse(args, stdout, stderr)
+	}
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd.run(args[1:], stdout, stderr)
+		}
+	}
+	fmt.Fprintf(stderr, "smparser: unknown command %q\n", name)
+	usage(stderr)
+	return exitUsage
+}
 
-	// Write to disk as JSON.
-	outDir := "../testdata"
-	err = parser.WriteJSON(sim, outDir)
-	parser.CheckError(err)
+// usage prints the list of commands.
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: smparser <command> [flags] <inputs>")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-9s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run \"smparser <command> -h\" for the flags of a command.")
 }
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"go-sm-parser/parser"
+)
+
+func TestTableRunExitCodes(t *testing.T) {
+	var tests = []struct {
+		args []string
+		code int
+	}{
+		{[]string{}, exitUsage},
+		{[]string{"help"}, exitOK},
+		{[]string{"unknown"}, exitUsage},
+		{[]string{"parse"}, exitUsage},
+		{[]string{"parse", "-bogus", "../testdata"}, exitUsage},
+		{[]string{"convert", "-format", "bogus", "../testdata"}, exitUsage},
+		{[]string{"stats", "-format", "bogus", "../testdata"}, exitUsage},
+		{[]string{"info", "-h"}, exitOK},
+		{[]string{"info", "-r", "../testdata"}, exitOK},
+		{[]string{"validate", "-r", "../testdata"}, exitOK},
+		{[]string{"validate", "../testdata/README.md"}, exitFailure},
+		{[]string{"info", "../testdata/missing.sm"}, exitFailure},
+		{[]string{"stats", "../testdata/*.sm"}, exitFailure},
+		{[]string{"scan", "../testdata"}, exitOK},
+		{[]string{"parse", "-collision", "bogus", "../testdata"}, exitUsage},
+		{[]string{"schema"}, exitOK},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(test.args, &stdout, &stderr); code != test.code {
+			errorMsg := fmt.Sprintf("Expected exit code %d for %v, received: %d (%s)", test.code, test.args, code, stderr.String())
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestExpandInputs(t *testing.T) {
+	var tests = []struct {
+		args      []string
+		recursive bool
+		count     int
+	}{
+		{[]string{"../testdata/sharpnelstreamz/bluearmy/bluearmy.sm"}, false, 1},
+		{[]string{"../testdata/sharpnelstreamz/*/*.sm"}, false, 2},
+		{[]string{"../testdata/sharpnelstreamz/bluearmy"}, false, 1},
+		{[]string{"../testdata/sharpnelstreamz"}, true, 2},
+	}
+	for _, test := range tests {
+		paths, err := expandInputs(test.args, test.recursive)
+		if err != nil || len(paths) != test.count {
+			errorMsg := fmt.Sprintf("Expected %d inputs for %v, received: %v (%v)", test.count, test.args, paths, err)
+			t.Error(errorMsg)
+		}
+	}
+
+	if _, err := expandInputs([]string{"../testdata/sharpnelstreamz"}, false); err == nil {
+		t.Error("Expected an error for a directory without simfiles.")
+	}
+}
+
+func TestSimfilesInKSF(t *testing.T) {
+	Fs := afero.NewOsFs()
+	dir, _ := afero.TempDir(Fs, "", "smparser")
+	defer Fs.RemoveAll(dir)
+	for _, name := range []string{"kiu/Crazy_1.ksf", "kiu/Easy_1.ksf", "kiu/song.mp3", "other/song.dwi", "other/song.ksf"} {
+		Fs.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755)
+		afero.WriteFile(Fs, filepath.Join(dir, name), []byte{}, 0644)
+	}
+
+	paths, err := simfilesIn(dir, true)
+	if err != nil || len(paths) != 2 || filepath.Base(paths[0]) != "Crazy_1.ksf" || filepath.Base(paths[1]) != "song.dwi" {
+		errorMsg := fmt.Sprintf("Expected one .ksf and one .dwi input, received: %v (%v)", paths, err)
+		t.Error(errorMsg)
+	}
+}
+
+func TestRunParse(t *testing.T) {
+	Fs := afero.NewOsFs()
+	outDir, _ := afero.TempDir(Fs, "", "smparser")
+	defer Fs.RemoveAll(outDir)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"parse", "-o", outDir, "-r", "../testdata"}, &stdout, &stderr); code != exitOK {
+		t.Fatal(fmt.Sprintf("Expected exit code 0, received: %d (%s)", code, stderr.String()))
+	}
+	files, _ := afero.ReadDir(Fs, outDir)
+	if len(files) != 2 {
+		errorMsg := fmt.Sprintf("Expected 2 JSON files, received: %d", len(files))
+		t.Error(errorMsg)
+	}
+
+	stdout.Reset()
+	run([]string{"parse", "-stdout", "../testdata/sharpnelstreamz/bluearmy/bluearmy.sm"}, &stdout, &stderr)
+	if !strings.HasPrefix(stdout.String(), `{"schema_version":1,"song_pack":"sharpnelstreamz"`) {
+		errorMsg := fmt.Sprintf("Expected JSON on stdout, received: %.40s", stdout.String())
+		t.Error(errorMsg)
+	}
+}
+
+func TestRunParseNaming(t *testing.T) {
+	Fs := afero.NewOsFs()
+	outDir, _ := afero.TempDir(Fs, "", "smparser")
+	defer Fs.RemoveAll(outDir)
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"parse", "-o", outDir, "-mirror", "../testdata", "-name", "{file}", "-r", "../testdata"}
+	if code := run(args, &stdout, &stderr); code != exitOK {
+		t.Fatal(fmt.Sprintf("Expected exit code 0, received: %d (%s)", code, stderr.String()))
+	}
+	var files = []string{"sharpnelstreamz/bluearmy/bluearmy.json", "sharpnelstreamz/200312023/twothousand.json"}
+	for _, file := range files {
+		if exists, _ := afero.Exists(Fs, outDir+"/"+file); !exists {
+			errorMsg := fmt.Sprintf("Expected %s to be written.", file)
+			t.Error(errorMsg)
+		}
+	}
+
+	args = []string{"parse", "-o", outDir, "-collision", "error", "-name", "{pack}", "-r", "../testdata"}
+	if code := run(args, &stdout, &stderr); code != exitFailure {
+		errorMsg := fmt.Sprintf("Expected exit code 1 for colliding names, received: %d", code)
+		t.Error(errorMsg)
+	}
+}
+
+func TestRunConvertNDJSON(t *testing.T) {
+	var tests = []struct {
+		args  []string
+		lines int
+		code  int
+	}{
+		{[]string{"convert", "-format", "ndjson", "-r", "../testdata"}, 2, exitOK},
+		{[]string{"convert", "-format", "ndjson-charts", "-r", "../testdata"}, 6, exitOK},
+		{[]string{"convert", "-format", "ndjson", "../testdata/README.md", "../testdata/sharpnelstreamz/bluearmy"}, 1, exitFailure},
+		{[]string{"scan", "-format", "ndjson", "../testdata"}, 2, exitOK},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(test.args, &stdout, &stderr)
+		if lines := strings.Count(stdout.String(), "\n"); code != test.code || lines != test.lines {
+			errorMsg := fmt.Sprintf("Expected %d lines and exit code %d for %v, received: %d lines, %d", test.lines, test.code, test.args, lines, code)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestRunStatsJSON(t *testing.T) {
+	Fs := afero.NewOsFs()
+	outDir, _ := afero.TempDir(Fs, "", "smparser")
+	defer Fs.RemoveAll(outDir)
+
+	var stdout, stderr bytes.Buffer
+	run([]string{"parse", "-o", outDir, "../testdata/sharpnelstreamz/bluearmy/bluearmy.sm"}, &stdout, &stderr)
+	run([]string{"stats", "../testdata/sharpnelstreamz/bluearmy/bluearmy.sm"}, &stdout, &stderr)
+	parsed := stdout.String()
+
+	stdout.Reset()
+	if code := run([]string{"stats", outDir + "/Blue Army.json"}, &stdout, &stderr); code != exitOK {
+		t.Fatal(fmt.Sprintf("Expected exit code 0, received: %d (%s)", code, stderr.String()))
+	}
+	if stdout.String() != parsed {
+		errorMsg := fmt.Sprintf("Expected the stats of the simfile, received: %s", stdout.String())
+		t.Error(errorMsg)
+	}
+}
+
+func TestRunScanSQLite(t *testing.T) {
+	Fs := afero.NewOsFs()
+	outDir, _ := afero.TempDir(Fs, "", "smparser")
+	defer Fs.RemoveAll(outDir)
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"scan", "-sqlite", outDir + "/library.db", "../testdata"}
+	run(args, &stdout, &stderr)
+	stdout.Reset()
+	if code := run(args, &stdout, &stderr); code != exitOK || stdout.String() != "../testdata: 0 inserted, 0 updated, 2 unchanged, 0 deleted\n" {
+		errorMsg := fmt.Sprintf("Expected an unchanged rescan, received: %d %s%s", code, stdout.String(), stderr.String())
+		t.Error(errorMsg)
+	}
+}
+
+func TestRunScanCache(t *testing.T) {
+	Fs := afero.NewOsFs()
+	cacheDir, _ := afero.TempDir(Fs, "", "smparser")
+	defer Fs.RemoveAll(cacheDir)
+
+	var stdout, stderr bytes.Buffer
+	for i := 0; i < 2; i++ {
+		if code := run([]string{"scan", "-cache", cacheDir, "../testdata"}, &stdout, &stderr); code != exitOK {
+			t.Fatal(fmt.Sprintf("Expected exit code 0, received: %d (%s)", code, stderr.String()))
+		}
+	}
+	if files, _ := afero.ReadDir(Fs, cacheDir); len(files) != 2 {
+		errorMsg := fmt.Sprintf("Expected 2 cached songs, received: %d", len(files))
+		t.Error(errorMsg)
+	}
+}
+
+// closedWriter fails every write, like a pipe whose reader exited.
+type closedWriter struct{}
+
+func (closedWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("write |1: broken pipe")
+}
+
+func TestRunWriteErrors(t *testing.T) {
+	var tests = [][]string{
+		{"scan", "-format", "ndjson", "../testdata"},
+		{"scan", "-format", "json", "../testdata"},
+		{"stats", "-format", "json", "../testdata/sharpnelstreamz/bluearmy/bluearmy.sm"},
+		{"info", "-format", "json", "../testdata/sharpnelstreamz/bluearmy/bluearmy.sm"},
+		{"schema"},
+	}
+
+	for _, args := range tests {
+		var stderr bytes.Buffer
+		if code := run(args, closedWriter{}, &stderr); code != exitFailure || !strings.Contains(stderr.String(), "broken pipe") {
+			errorMsg := fmt.Sprintf("Expected exit code 1 and the write error for %v, received: %d (%s)", args, code, stderr.String())
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestRunConvertCSV(t *testing.T) {
+	Fs := afero.NewOsFs()
+	outDir, _ := afero.TempDir(Fs, "", "smparser")
+	defer Fs.RemoveAll(outDir)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"convert", "-format", "csv", "-notes", "-o", outDir, "-r", "../testdata"}, &stdout, &stderr); code != exitOK {
+		t.Fatal(fmt.Sprintf("Expected exit code 0, received: %d (%s)", code, stderr.String()))
+	}
+	charts, _ := afero.ReadFile(Fs, outDir+"/charts.csv")
+	notes, _ := afero.ReadFile(Fs, outDir+"/notes.csv")
+	if lines := strings.Count(string(charts), "\n"); lines != 7 {
+		errorMsg := fmt.Sprintf("Expected a header and 6 charts, received: %d lines", lines)
+		t.Error(errorMsg)
+	}
+	if !strings.HasPrefix(string(notes), "chart_id,measure,beat") {
+		t.Error("Expected a notes table.")
+	}
+	if files, _ := afero.ReadDir(Fs, outDir); len(files) != 2 {
+		errorMsg := fmt.Sprintf("Expected only the two tables, without temporary files, received: %d files", len(files))
+		t.Error(errorMsg)
+	}
+
+	if code := run([]string{"convert", "-format", "csv", "-notes", "-stdout", "../testdata"}, &stdout, &stderr); code != exitUsage {
+		errorMsg := fmt.Sprintf("Expected exit code 2 for -notes with -stdout, received: %d", code)
+		t.Error(errorMsg)
+	}
+}
+
+func TestRunConvertCSVFromJSON(t *testing.T) {
+	Fs := afero.NewOsFs()
+	outDir, _ := afero.TempDir(Fs, "", "smparser")
+	defer Fs.RemoveAll(outDir)
+
+	var stdout, stderr bytes.Buffer
+	run([]string{"parse", "-o", outDir, "-name", "song", "../testdata/sharpnelstreamz/bluearmy/bluearmy.sm"}, &stdout, &stderr)
+	args := []string{"convert", "-format", "csv", "-o", outDir, outDir + "/song.json", "../testdata/sharpnelstreamz/200312023"}
+	if code := run(args, &stdout, &stderr); code != exitOK {
+		t.Fatal(fmt.Sprintf("Expected exit code 0, received: %d (%s)", code, stderr.String()))
+	}
+	charts, _ := afero.ReadFile(Fs, outDir+"/charts.csv")
+	if lines := strings.Count(string(charts), "\n"); lines != 7 || !strings.Contains(string(charts), "Blue Army") {
+		errorMsg := fmt.Sprintf("Expected a header and 6 charts including the JSON song, received: %d lines", lines)
+		t.Error(errorMsg)
+	}
+}
+
+func TestRunConvertParquet(t *testing.T) {
+	Fs := afero.NewOsFs()
+	outDir, _ := afero.TempDir(Fs, "", "smparser")
+	defer Fs.RemoveAll(outDir)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"convert", "-format", "parquet", "-notes", "-o", outDir, "-r", "../testdata"}, &stdout, &stderr); code != exitOK {
+		t.Fatal(fmt.Sprintf("Expected exit code 0, received: %d (%s)", code, stderr.String()))
+	}
+	for _, name := range []string{"charts.parquet", "notes.parquet"} {
+		data, _ := afero.ReadFile(Fs, outDir+"/"+name)
+		if !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+			errorMsg := fmt.Sprintf("Expected a Parquet file for %s.", name)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestRunConvertProto(t *testing.T) {
+	Fs := afero.NewOsFs()
+	outDir, _ := afero.TempDir(Fs, "", "smparser")
+	defer Fs.RemoveAll(outDir)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"convert", "-format", "proto", "-o", outDir, "-r", "../testdata"}, &stdout, &stderr); code != exitOK {
+		t.Fatal(fmt.Sprintf("Expected exit code 0, received: %d (%s)", code, stderr.String()))
+	}
+	data, _ := afero.ReadFile(Fs, outDir+"/Blue Army.pb")
+	if sim, err := parser.UnmarshalProto(data); err != nil || sim.Header.Title != "Blue Army" {
+		errorMsg := fmt.Sprintf("Expected a protobuf file, received: %v", err)
+		t.Error(errorMsg)
+	}
+
+	run([]string{"convert", "-format", "proto", "-stdout", "-r", "../testdata"}, &stdout, &stderr)
+	reader := bufio.NewReader(&stdout)
+	for _, title := range []string{"20031023", "Blue Army"} {
+		if sim, err := parser.ReadProtoDelimited(reader); err != nil || sim.Header.Title != title {
+			errorMsg := fmt.Sprintf("Expected %s, received: %s (%v)", title, sim.Header.Title, err)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestRunConvertUCS(t *testing.T) {
+	Fs := afero.NewOsFs()
+	outDir, _ := afero.TempDir(Fs, "", "smparser")
+	defer Fs.RemoveAll(outDir)
+	ucs := ":Format=1\r\n:Mode=Double\r\n:BPM=150\r\n:Delay=0\r\n:Beat=4\r\n:Split=1\r\nX........X\r\n"
+	afero.WriteFile(Fs, outDir+"/CS001.ucs", []byte(ucs), 0644)
+
+	var tests = []struct {
+		args []string
+		code int
+	}{
+		{[]string{"convert", "-format", "ucs", "-o", outDir + "/out", outDir + "/CS001.ucs"}, exitOK},
+		{[]string{"convert", "-format", "ucs", "-stdout", outDir + "/CS001.ucs"}, exitUsage},
+		{[]string{"convert", "-format", "ucs", "-o", outDir + "/out", "../testdata/sharpnelstreamz/bluearmy/bluearmy.sm"}, exitFailure},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(test.args, &stdout, &stderr); code != test.code {
+			errorMsg := fmt.Sprintf("Expected exit code %d for %v, received: %d (%s)", test.code, test.args, code, stderr.String())
+			t.Error(errorMsg)
+		}
+	}
+
+	sim, err := parser.ParseFile(outDir + "/out/CS001 Edit.ucs")
+	if err != nil || len(sim.Charts) != 1 || sim.Charts[0].Type != "pump-double" {
+		errorMsg := fmt.Sprintf("Expected a pump-double UCS file, received: %v", err)
+		t.Error(errorMsg)
+	}
+}
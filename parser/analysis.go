@@ -0,0 +1,29 @@
+package parser
+
+import "strings"
+
+// Analysis contains values derived from a chart's note data and timing.
+type Analysis struct {
+	Density    Density    `json:"density"`
+	Patterns   Patterns   `json:"patterns"`
+	Parity     Parity     `json:"parity"`
+	Difficulty Difficulty `json:"difficulty"`
+}
+
+// Analyze computes the Analysis for a parsed chart.
+//
+// It also sets the foot assignment (Step.Feet) of every note in the chart. Pump charts only get
+// their Density, since patterns, parity and difficulty are modeled on the four dance panels.
+func Analyze(chart Chart, header Header) Analysis {
+	timing := NewTiming(header)
+	if strings.HasPrefix(chart.Type, "pump-") {
+		return Analysis{Density: chartDensity(chart, timing)}
+	}
+	parity := SolveParity(chart.Notes, timing)
+	return Analysis{
+		Density:    chartDensity(chart, timing),
+		Patterns:   detectPatterns(chart),
+		Parity:     parity,
+		Difficulty: estimateDifficulty(chart, timing),
+	}
+}
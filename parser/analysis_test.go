@@ -0,0 +1,12 @@
+package parser
+
+import "testing"
+
+func TestAnalyze(t *testing.T) {
+	header := Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 120}}}
+	chart := Chart{Notes: noteData("1000010000100001")}
+	analysis := Analyze(chart, header)
+	if analysis.Density.PeakNPS != 2.0 {
+		t.Error("Analysis did not include chart density.")
+	}
+}
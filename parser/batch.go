@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"runtime"
+	"sync"
+)
+
+// BatchOptions configures ParseBatch.
+type BatchOptions struct {
+	// Workers is the number of files parsed at once. It defaults to the number of CPUs.
+	Workers int
+	// Ordered sends results in the order of the paths instead of as they finish.
+	Ordered bool
+	// Parse configures how each file is parsed.
+	Parse ParseOptions
+	// Cache, when set, is used to skip parsing files that have not changed.
+	Cache *Cache
+	// FS, when set, is the file system the paths are read from instead of the OS.
+	FS fs.FS
+	// FSPath is where FS is located, such as a directory or zip file. It identifies the
+	// files of FS in the Cache.
+	FSPath string
+	// Progress is called after each file with the number of files done and the total.
+	// It is called from a single goroutine.
+	Progress func(done int, total int)
+}
+
+// BatchResult is the outcome of parsing one file in a batch.
+type BatchResult struct {
+	Index   int
+	Path    string
+	Simfile Simfile
+	Err     error
+}
+
+// ParseBatch parses simfiles concurrently with ParseFileWith and sends the results over a channel.
+//
+// The channel is closed once every file is parsed, or early when ctx is cancelled.
+func ParseBatch(ctx context.Context, paths []string, options BatchOptions) <-chan BatchResult {
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan int)
+	parsed := make(chan BatchResult, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				sim, err := parseBatchFile(paths[i], options)
+				select {
+				case parsed <- BatchResult{Index: i, Path: paths[i], Simfile: sim, Err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := range paths {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(parsed)
+	}()
+
+	results := make(chan BatchResult)
+	go func() {
+		defer close(results)
+		pending := map[int]BatchResult{}
+		next, done := 0, 0
+		for result := range parsed {
+			done++
+			if options.Progress != nil {
+				options.Progress(done, len(paths))
+			}
+
+			ready := []BatchResult{result}
+			if options.Ordered {
+				pending[result.Index] = result
+				ready = ready[:0]
+				for r, ok := pending[next]; ok; r, ok = pending[next] {
+					ready = append(ready, r)
+					delete(pending, next)
+					next++
+				}
+			}
+			for _, r := range ready {
+				select {
+				case results <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return results
+}
+
+// ParseAll parses simfiles concurrently and returns the results in the order of the paths.
+//
+// Per-file errors are kept in each BatchResult; the returned error is set only when ctx is cancelled.
+func ParseAll(ctx context.Context, paths []string, options BatchOptions) ([]BatchResult, error) {
+	options.Ordered = true
+	results := make([]BatchResult, 0, len(paths))
+	for result := range ParseBatch(ctx, paths, options) {
+		results = append(results, result)
+	}
+	if len(results) < len(paths) {
+		return results, ctx.Err()
+	}
+	return results, nil
+}
+
+// parseBatchFile parses one file of a batch, through the cache if there is one.
+func parseBatchFile(smPath string, options BatchOptions) (Simfile, error) {
+	switch {
+	case options.FS != nil && options.Cache != nil:
+		return options.Cache.ParseFS(options.FS, smPath, path.Join(options.FSPath, smPath), options.Parse)
+	case options.FS != nil:
+		return ParseFSWith(options.FS, smPath, options.Parse)
+	case options.Cache != nil:
+		return options.Cache.ParseFile(smPath, options.Parse)
+	}
+	return ParseFileWith(smPath, options.Parse)
+}
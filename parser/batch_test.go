@@ -0,0 +1,62 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+var batchPaths = []string{
+	"../testdata/sharpnelstreamz/bluearmy/bluearmy.sm",
+	"../testdata/README.md",
+	"../testdata/sharpnelstreamz/200312023/twothousand.sm",
+}
+
+func TestParseAll(t *testing.T) {
+	progress := 0
+	options := BatchOptions{Workers: 2, Progress: func(done int, total int) {
+		if total != len(batchPaths) {
+			t.Error("Progress reported the wrong total.")
+		}
+		progress = done
+	}}
+
+	results, err := ParseAll(context.Background(), batchPaths, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress != len(batchPaths) {
+		errorMsg := fmt.Sprintf("Expected progress %d, received: %d", len(batchPaths), progress)
+		t.Error(errorMsg)
+	}
+	for i, result := range results {
+		if result.Index != i || result.Path != batchPaths[i] {
+			t.Error("Results are not in path order.")
+		}
+	}
+	if results[0].Simfile.Header.Title != "Blue Army" || results[0].Err != nil {
+		t.Error("Simfile not parsed.")
+	}
+	if results[1].Err == nil {
+		t.Error("Expected an error for a non .sm file.")
+	}
+}
+
+func TestParseBatchUnordered(t *testing.T) {
+	seen := map[int]bool{}
+	for result := range ParseBatch(context.Background(), batchPaths, BatchOptions{}) {
+		seen[result.Index] = true
+	}
+	if len(seen) != len(batchPaths) {
+		errorMsg := fmt.Sprintf("Expected %d results, received: %d", len(batchPaths), len(seen))
+		t.Error(errorMsg)
+	}
+}
+
+func TestParseAllCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ParseAll(ctx, batchPaths, BatchOptions{Workers: 1}); err != context.Canceled {
+		t.Error("Expected a cancelled batch to return the context error.")
+	}
+}
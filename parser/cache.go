@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// CacheVersion identifies the parser output stored in a Cache. It must be bumped whenever
+// parsing or analysis changes, so entries written by older versions are parsed again.
+const CacheVersion = 6
+
+// Cache stores parsed Simfiles on disk, so unchanged files are not parsed again.
+type Cache struct {
+	Dir string
+}
+
+// cacheEntry is the gob encoded contents of a cache file.
+type cacheEntry struct {
+	Version int
+	Path    string
+	ModTime int64
+	Size    int64
+	Hash    string
+	Options ParseOptions
+	Simfile Simfile
+}
+
+// NewCache returns a Cache storing its entries in dir, creating it if needed.
+func NewCache(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Cache{Dir: dir}, nil
+}
+
+// ParseFile returns the cached Simfile for a file, parsing it with ParseFileWith when the file
+// changed since it was cached.
+//
+// An entry is reused without reading the file when its mtime and size are unchanged, or after
+// reading it when its content hash is unchanged. Failures to write the cache are ignored, since
+// the parsed Simfile is still valid.
+func (c *Cache) ParseFile(smPath string, options ParseOptions) (Simfile, error) {
+	key, err := filepath.Abs(smPath)
+	if err != nil {
+		return Simfile{}, err
+	}
+	return c.ParseFS(os.DirFS(filepath.Dir(key)), filepath.Base(key), key, options)
+}
+
+// ParseFS returns the cached Simfile for a file in a file system, like ParseFile.
+//
+// The key identifies the file across file systems, such as the zip path joined with name.
+// It also names the pack of the Simfile. The entry of a .ksf file tracks every .ksf file of
+// its song folder, which are parsed together.
+func (c *Cache) ParseFS(fsys fs.FS, name string, key string, options ParseOptions) (Simfile, error) {
+	files, modTime, size, err := cacheFiles(fsys, name)
+	if err != nil {
+		return Simfile{}, err
+	}
+	entry, cached := c.load(key)
+	cached = cached && entry.Version == CacheVersion && entry.Path == key && entry.Options == options
+	if cached && entry.ModTime == modTime && entry.Size == size {
+		return entry.Simfile, nil
+	}
+
+	contents := sha256.New()
+	var data []byte
+	for _, file := range files {
+		if data, err = readSimfileFS(fsys, file); err != nil {
+			return Simfile{}, err
+		}
+		if len(files) > 1 {
+			contents.Write([]byte(path.Base(file) + "\x00"))
+		}
+		contents.Write(data)
+	}
+	hash := hex.EncodeToString(contents.Sum(nil))
+	if !cached || entry.Hash != hash {
+		var sim Simfile
+		if isKSF(name) {
+			sim, err = parseKSFNamed(fsys, path.Dir(name), key, options)
+		} else {
+			sim, err = parseNamed(data, nil, key, options)
+		}
+		if err != nil {
+			return Simfile{}, err
+		}
+		entry = cacheEntry{Version: CacheVersion, Path: key, Hash: hash, Options: options, Simfile: sim}
+	}
+	entry.ModTime = modTime
+	entry.Size = size
+	c.store(entry)
+	return entry.Simfile, nil
+}
+
+// cacheFiles returns the files a simfile is parsed from, with their latest mtime and total size.
+// For a .ksf file these are the .ksf files of its song folder, and the mtime of the folder
+// counts too, since it changes when a chart is added or removed.
+func cacheFiles(fsys fs.FS, name string) ([]string, int64, int64, error) {
+	files := []string{name}
+	stats := []string{name}
+	if isKSF(name) {
+		dir := path.Dir(name)
+		names, err := ksfNames(fsys, dir)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		files = files[:0]
+		for _, ksf := range names {
+			files = append(files, path.Join(dir, ksf))
+		}
+		stats = append([]string{dir}, files...)
+	}
+
+	var modTime, size int64
+	for i, file := range stats {
+		info, err := fs.Stat(fsys, file)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		if mtime := info.ModTime().UnixNano(); i == 0 || mtime > modTime {
+			modTime = mtime
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+	}
+	return files, modTime, size, nil
+}
+
+// entryPath returns the cache file of a simfile path.
+func (c *Cache) entryPath(key string) string {
+	sum := sha1.Sum([]byte(key))
+	return path.Join(c.Dir, hex.EncodeToString(sum[:])+".gob")
+}
+
+// load reads the cache entry of a simfile path.
+func (c *Cache) load(key string) (cacheEntry, bool) {
+	entry := cacheEntry{}
+	data, err := ioutil.ReadFile(c.entryPath(key))
+	if err != nil {
+		return entry, false
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+// store writes a cache entry, replacing the previous file atomically.
+func (c *Cache) store(entry cacheEntry) error {
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(entry); err != nil {
+		return err
+	}
+	return writeFileAtomic(c.entryPath(entry.Path), data.Bytes(), 0644)
+}
@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+)
+
+// cacheFixture writes a simfile and creates a cache in a temp directory.
+func cacheFixture(t *testing.T) (string, *Cache, func()) {
+	var Fs = afero.NewOsFs()
+	dir, _ := afero.TempDir(Fs, "", "_")
+	smPath := path.Join(dir, "pack", "song", "song.sm")
+	Fs.MkdirAll(path.Dir(smPath), 0755)
+	afero.WriteFile(Fs, smPath, []byte("#TITLE:First;"), 0644)
+
+	cache, err := NewCache(path.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return smPath, cache, func() { Fs.RemoveAll(dir) }
+}
+
+func TestCacheParseFile(t *testing.T) {
+	smPath, cache, cleanup := cacheFixture(t)
+	defer cleanup()
+
+	sim, err := cache.ParseFile(smPath, ParseOptions{})
+	if err != nil || sim.Header.Title != "First" || sim.SongPack != "pack" {
+		t.Fatal("Simfile not parsed on a cache miss.")
+	}
+
+	// Same size and mtime => the cached entry is used without reading the file.
+	info, _ := os.Stat(smPath)
+	os.WriteFile(smPath, []byte("#TITLE:Other;"), 0644)
+	os.Chtimes(smPath, info.ModTime(), info.ModTime())
+	if sim, _ := cache.ParseFile(smPath, ParseOptions{}); sim.Header.Title != "First" {
+		t.Error("Unchanged file was parsed again.")
+	}
+
+	// New mtime => the content hash changed, so the file is parsed again.
+	later := info.ModTime().Add(time.Second)
+	os.Chtimes(smPath, later, later)
+	if sim, _ := cache.ParseFile(smPath, ParseOptions{}); sim.Header.Title != "Other" {
+		t.Error("Changed file was not parsed again.")
+	}
+
+	// Different options => the entry does not match.
+	os.WriteFile(smPath, []byte("#TITLE:Third;"), 0644)
+	os.Chtimes(smPath, later, later)
+	if sim, _ := cache.ParseFile(smPath, ParseOptions{HeaderOnly: true}); sim.Header.Title != "Third" {
+		t.Error("Entry was used with different parse options.")
+	}
+}
+
+func TestCacheVersion(t *testing.T) {
+	smPath, cache, cleanup := cacheFixture(t)
+	defer cleanup()
+
+	key, _ := filepath.Abs(smPath)
+	info, _ := os.Stat(smPath)
+	stale := cacheEntry{Version: CacheVersion - 1, Path: key, ModTime: info.ModTime().UnixNano(), Size: info.Size(),
+		Simfile: Simfile{Header: Header{Title: "Stale"}}}
+	if err := cache.store(stale); err != nil {
+		t.Fatal(err)
+	}
+
+	if sim, _ := cache.ParseFile(smPath, ParseOptions{}); sim.Header.Title != "First" {
+		t.Error("Entry from an older cache version was used.")
+	}
+	if entry, ok := cache.load(key); !ok || entry.Version != CacheVersion {
+		t.Error("Stale entry was not replaced.")
+	}
+}
+
+func TestCacheParseFileErrors(t *testing.T) {
+	_, cache, cleanup := cacheFixture(t)
+	defer cleanup()
+
+	if _, err := cache.ParseFile("../testdata/missing.sm", ParseOptions{}); err == nil {
+		t.Error("Expected an error for a missing file.")
+	}
+	if _, err := cache.ParseFile("../testdata/README.md", ParseOptions{}); err == nil {
+		t.Error("Expected an error for a non .sm file.")
+	}
+}
+
+func TestParseAllWithCache(t *testing.T) {
+	smPath, cache, cleanup := cacheFixture(t)
+	defer cleanup()
+
+	results, _ := ParseAll(context.Background(), []string{smPath}, BatchOptions{Cache: cache})
+	if results[0].Simfile.Header.Title != "First" {
+		t.Error("Batch did not parse through the cache.")
+	}
+	key, _ := filepath.Abs(smPath)
+	if _, ok := cache.load(key); !ok {
+		t.Error("Batch did not store the cache entry.")
+	}
+}
+
+func TestCacheParseKSF(t *testing.T) {
+	smPath, cache, cleanup := cacheFixture(t)
+	defer cleanup()
+
+	songDir := path.Join(path.Dir(path.Dir(smPath)), "kiu")
+	os.MkdirAll(songDir, 0755)
+	os.WriteFile(path.Join(songDir, "Crazy_1.ksf"), []byte(testKSF), 0644)
+	ksfPath := path.Join(songDir, "Crazy_1.ksf")
+	if sim, err := cache.ParseFile(ksfPath, ParseOptions{}); err != nil || len(sim.Charts) != 1 || sim.SongPack != "pack" {
+		t.Fatal("KSF song not parsed on a cache miss.")
+	}
+	key, _ := filepath.Abs(ksfPath)
+	if _, ok := cache.load(key); !ok {
+		t.Error("KSF song not stored in the cache.")
+	}
+
+	// A chart added to the song folder changes the entry, though Crazy_1.ksf is unchanged.
+	os.WriteFile(path.Join(songDir, "Double.ksf"), []byte(testKSFDouble), 0644)
+	later := time.Now().Add(time.Second)
+	os.Chtimes(songDir, later, later)
+	if sim, _ := cache.ParseFile(ksfPath, ParseOptions{}); len(sim.Charts) != 2 {
+		t.Error("KSF song was not parsed again after a chart was added.")
+	}
+}
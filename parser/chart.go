@@ -1,7 +1,8 @@
 package parser
 
 import (
-	"math"
+	"bytes"
+	"errors"
 	"strconv"
 	"strings"
 )
@@ -15,6 +16,7 @@ type Chart struct {
 	Meter       int       `json:"meter"`
 	GrooveRadar Radar     `json:"grooveradar"`
 	Notes       []Measure `json:"notes"`
+	Analysis    Analysis  `json:"analysis"`
 }
 
 // Radar represents the 5 GrooveRadar attributes.
@@ -40,9 +42,50 @@ type Step struct {
 	D    string  `json:"d"`
 	U    string  `json:"u"`
 	R    string  `json:"r"`
+	Feet string  `json:"feet,omitempty"`
+	// Columns holds the step values of charts without the four dance panels, such as
+	// pump-single, one character per column. L, D, U and R are empty for them.
+	Columns string `json:"columns,omitempty"`
 }
 
-func calcQuantization(measure string) int {
+// Panels returns the step values in L, D, U, R order, or in column order for Columns.
+func (s Step) Panels() []string {
+	if s.Columns != "" {
+		panels := make([]string, len(s.Columns))
+		for i := range panels {
+			panels[i] = panelValues[s.Columns[i]]
+		}
+		return panels
+	}
+	return []string{s.L, s.D, s.U, s.R}
+}
+
+// isNote reports whether a step value is hit by the player (tap, hold/roll head or lift).
+func isNote(value string) bool {
+	return value == "1" || value == "2" || value == "4" || value == "L"
+}
+
+// noteCount returns the number of notes hit in a step.
+func noteCount(step Step) int {
+	count := 0
+	for _, panel := range step.Panels() {
+		if isNote(panel) {
+			count++
+		}
+	}
+	return count
+}
+
+// panelValues holds the one character strings of each byte, so steps can be built without
+// allocating a string per panel.
+var panelValues = func() (values [256]string) {
+	for i := range values {
+		values[i] = string([]byte{byte(i)})
+	}
+	return values
+}()
+
+func calcQuantization[T ~string | ~[]byte](measure T) int {
 	return len(measure) / 4
 }
 
@@ -50,24 +93,17 @@ func calcBeat(measureNumber int, beatPart float64, measureIndex int) float64 {
 	return 4 * (float64(measureNumber) + (beatPart * float64(measureIndex/4)))
 }
 
-func splitSteps(measure string, measureNumber int, quantization int) []Step {
-	steps := []Step{}
-	step := Step{}
+func splitSteps[T ~string | ~[]byte](measure T, measureNumber int, quantization int) []Step {
+	steps := make([]Step, 0, len(measure)/4)
 	beatPart := 1.00 / float64(quantization)
-	for i, m := range measure {
-		switch remainder := math.Mod(float64(i+1), 4); remainder {
-		case 1:
-			step.L = string(m)
-		case 2:
-			step.D = string(m)
-		case 3:
-			step.U = string(m)
-		case 0:
-			step.R = string(m)
-			step.Beat = calcBeat(measureNumber, beatPart, i)
-			steps = append(steps, step)
-			step = Step{}
-		}
+	for i := 0; i+4 <= len(measure); i += 4 {
+		steps = append(steps, Step{
+			Beat: calcBeat(measureNumber, beatPart, i),
+			L:    panelValues[measure[i]],
+			D:    panelValues[measure[i+1]],
+			U:    panelValues[measure[i+2]],
+			R:    panelValues[measure[i+3]],
+		})
 	}
 	return steps
 }
@@ -84,23 +120,57 @@ func RawNoteValue(tag string) []string {
 	return noteValue
 }
 
+// noteFields splits the value of a Notes tag into its 6 metadata fields and the note block,
+// which is not copied. It returns an error if the tag has fewer fields.
+func noteFields(value []byte) ([]string, []byte, error) {
+	notes := []string{"#NOTES", "", "", "", "", ""}
+	for i := 1; i < len(notes); i++ {
+		colon := bytes.IndexByte(value, ':')
+		if colon < 0 {
+			return nil, nil, errors.New("Parse Error: Notes tag is missing fields")
+		}
+		notes[i] = strings.TrimSpace(string(value[:colon]))
+		value = value[colon+1:]
+	}
+	return notes, value, nil
+}
+
 // ExtractCharts parses the charts in the Notes tag
 func ExtractCharts(i int, notes []string, sim Simfile) Simfile {
 	// Only supports parsing singles
 	if notes[1] == "dance-single" {
-		sim.Charts[i].Type = notes[1]
-		sim.Charts[i].Description = notes[2]
-		sim.Charts[i].Difficulty = notes[3]
-		meter, err := strconv.Atoi(notes[4])
-		CheckError(err)
-		sim.Charts[i].Meter = meter
-		sim.Charts[i].GrooveRadar = radarCategory(notes[5])
+		sim = extractChartHeader(i, notes, sim)
 		sim.Charts[i].Notes = noteData(notes[6])
+		sim.Charts[i].Analysis = Analyze(sim.Charts[i], sim.Header)
 	}
 	sim.Charts[i].RawData = ""
 	return sim
 }
 
+// DecodeNotes parses the raw note block kept by ParseWith with HeaderOnly set, and analyzes
+// the chart.
+func DecodeNotes(chart Chart, header Header) Chart {
+	if chart.RawData == "" {
+		return chart
+	}
+	chart.Notes = noteData(chart.RawData)
+	chart.RawData = ""
+	chart.Analysis = Analyze(chart, header)
+	return chart
+}
+
+// extractChartHeader parses the chart type, description, difficulty, meter and radar values.
+func extractChartHeader(i int, notes []string, sim Simfile) Simfile {
+	sim.Charts[i].Type = notes[1]
+	sim.Charts[i].Description = notes[2]
+	sim.Charts[i].Difficulty = notes[3]
+	meter, err := strconv.Atoi(notes[4])
+	CheckError(err)
+	sim.Charts[i].Meter = meter
+	sim.Charts[i].GrooveRadar = radarCategory(notes[5])
+	return sim
+}
+
 // radarCategory parses the radar values
 func radarCategory(radar string) Radar {
 	categories := strings.Split(radar, ",")
@@ -127,14 +197,30 @@ func radarCategory(radar string) Radar {
 
 // noteData captures beat/measure information
 func noteData(notes string) []Measure {
-	measureSlices := []Measure{}
-	measures := strings.SplitAfter(notes, ",")
-	for measureNumber, measureString := range measures {
-		measureClean := strings.Replace(strings.Replace(measureString, "\r", "", -1), ",", "", -1)
-		quantization := calcQuantization(measureClean)
-		steps := splitSteps(measureClean, measureNumber, quantization)
-		measure := Measure{MeasureNumber: measureNumber, Quantization: quantization, Steps: steps}
-		measureSlices = append(measureSlices, measure)
+	return decodeMeasures([]byte(notes))
+}
+
+// decodeMeasures builds the Measures of a dance-single note block with a NoteScanner.
+func decodeMeasures(notes []byte) []Measure {
+	measures := []Measure{}
+	rows := []byte{}
+	flush := func(measureNumber int) {
+		quantization := calcQuantization(rows)
+		steps := splitSteps(rows, measureNumber, quantization)
+		measures = append(measures, Measure{MeasureNumber: measureNumber, Quantization: quantization, Steps: steps})
+		rows = rows[:0]
+	}
+
+	scanner := NewNoteScanner(notes, 4)
+	for scanner.Next() {
+		if scanner.Row() != nil {
+			rows = append(rows, scanner.Row()...)
+			continue
+		}
+		if scanner.Measure() > 0 {
+			flush(scanner.Measure() - 1)
+		}
 	}
-	return measureSlices
+	flush(len(measures))
+	return measures
 }
@@ -212,3 +212,10 @@ func TestExtractCharts(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeNotesWithoutRawData(t *testing.T) {
+	chart := Chart{Meter: 9}
+	if output := DecodeNotes(chart, Header{}); output.Meter != 9 || output.Notes != nil {
+		t.Error("DecodeNotes changed a chart without raw data.")
+	}
+}
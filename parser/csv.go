@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// ChartColumns are the columns of the charts table written by a CSVWriter.
+var ChartColumns = []string{
+	"chart_id", "path", "pack", "title", "subtitle", "artist", "chart_index", "type", "difficulty", "description",
+	"meter", "bpm_min", "bpm_max", "length_seconds", "notes", "jumps", "hands", "holds", "rolls", "mines",
+	"stream_measures", "stream_ratio", "peak_nps", "crossovers", "footswitches", "jacks", "estimate",
+}
+
+// NoteColumns are the columns of the notes table written by a CSVWriter, with a row per note.
+var NoteColumns = []string{"chart_id", "measure", "beat", "seconds", "snap", "column", "panel", "note", "foot"}
+
+// CSVWriter writes the charts of simfiles as a CSV table, and optionally their notes as a
+// second table joined on chart_id.
+//
+// Charts are numbered from 1 in the order they are written. Only parsed charts are written.
+// A CSVWriter is safe for concurrent use; call Flush when done.
+type CSVWriter struct {
+	mu     sync.Mutex
+	charts *csv.Writer
+	notes  *csv.Writer
+	nextID int64
+}
+
+// NewCSVWriter returns a CSVWriter writing the charts table to charts and the notes table to
+// notes, which may be nil to skip it. The header rows are written first.
+func NewCSVWriter(charts io.Writer, notes io.Writer) *CSVWriter {
+	w := &CSVWriter{charts: csv.NewWriter(charts), nextID: 1}
+	w.charts.Write(ChartColumns)
+	if notes != nil {
+		w.notes = csv.NewWriter(notes)
+		w.notes.Write(NoteColumns)
+	}
+	return w
+}
+
+// Write writes the charts of a simfile read from source, which may be empty.
+func (w *CSVWriter) Write(sim Simfile, source string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, chart := range sim.Charts {
+		if chart.Type == "" {
+			continue
+		}
+		id := w.nextID
+		w.nextID++
+
+		r := newChartRow(id, sim, source, i, chart)
+		record := []string{
+			itoa(r.ChartID), r.Path, r.Pack, r.Title, r.Subtitle, r.Artist, itoa(r.ChartIndex), r.Type,
+			r.Difficulty, r.Description, itoa(r.Meter), formatFloat(r.BPMMin), formatFloat(r.BPMMax),
+			formatFloat(r.LengthSeconds), itoa(r.Notes), itoa(r.Jumps), itoa(r.Hands), itoa(r.Holds),
+			itoa(r.Rolls), itoa(r.Mines), itoa(r.StreamMeasures), formatFloat(r.StreamRatio),
+			formatFloat(r.PeakNPS), itoa(r.Crossovers), itoa(r.Footswitches), itoa(r.Jacks), formatFloat(r.Estimate),
+		}
+		if err := w.charts.Write(record); err != nil {
+			return err
+		}
+		if w.notes == nil {
+			continue
+		}
+
+		for n := range noteTableRows(id, chart, sim.Header) {
+			record := []string{
+				itoa(n.ChartID), itoa(n.Measure), formatFloat(n.Beat), formatFloat(n.Seconds), itoa(n.Snap),
+				itoa(n.Column), n.Panel, n.Note, n.Foot,
+			}
+			if err := w.notes.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered rows and returns the first write error.
+func (w *CSVWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.charts.Flush()
+	if err := w.charts.Error(); err != nil {
+		return err
+	}
+	if w.notes != nil {
+		w.notes.Flush()
+		return w.notes.Error()
+	}
+	return nil
+}
+
+// formatFloat formats a float with as few digits as needed, writing -0 as 0.
+func formatFloat(value float64) string {
+	if value == 0 {
+		value = 0
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// itoa formats an integer column.
+func itoa[T int32 | int64](value T) string {
+	return strconv.FormatInt(int64(value), 10)
+}
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCSVWriter(t *testing.T) {
+	sim := Simfile{
+		SongPack: "Pack",
+		Header:   Header{Title: "Song, with comma", BPMs: []BeatChange{BeatChange{Beat: 0, Value: 120}}},
+		Charts:   []Chart{{}, {Type: "dance-single", Difficulty: "Hard", Meter: 9, Notes: noteData("1001,00M0")}},
+	}
+	sim.Charts[1].Analysis = Analyze(sim.Charts[1], sim.Header)
+
+	var charts, notes bytes.Buffer
+	writer := NewCSVWriter(&charts, &notes)
+	writer.Write(sim, "Pack/Song/song.sm")
+	writer.Write(sim, "Pack/Song/song.sm")
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	chartRecords, err := csv.NewReader(&charts).ReadAll()
+	if err != nil || len(chartRecords) != 3 {
+		t.Fatal(fmt.Sprintf("Expected a header and 2 charts, received: %v (%v)", chartRecords, err))
+	}
+	chart := map[string]string{}
+	for i, column := range ChartColumns {
+		chart[column] = chartRecords[2][i]
+	}
+	if chart["chart_id"] != "2" || chart["title"] != "Song, with comma" || chart["chart_index"] != "1" ||
+		chart["meter"] != "9" || chart["bpm_max"] != "120" || chart["notes"] != "1" || chart["jumps"] != "1" || chart["mines"] != "1" {
+		errorMsg := fmt.Sprintf("Chart row written incorrectly: %v", chart)
+		t.Error(errorMsg)
+	}
+
+	noteRecords, _ := csv.NewReader(&notes).ReadAll()
+	var expected = []string{
+		strings.Join(NoteColumns, "|"),
+		"1|0|0|0|4|0|left|tap|L",
+		"1|0|0|0|4|3|right|tap|R",
+		"1|1|4|2|4|2|up|mine|",
+	}
+	if len(noteRecords) != 7 {
+		t.Fatal(fmt.Sprintf("Expected a header and 6 notes, received: %d", len(noteRecords)))
+	}
+	for i, line := range expected {
+		if output := strings.Join(noteRecords[i], "|"); output != line {
+			errorMsg := fmt.Sprintf("Expected note row %s, received: %s", line, output)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestCSVWriterWithoutNotes(t *testing.T) {
+	var charts bytes.Buffer
+	writer := NewCSVWriter(&charts, nil)
+	writer.Write(Simfile{Charts: []Chart{{Type: "dance-single"}}}, "")
+	writer.Flush()
+	if lines := strings.Count(charts.String(), "\n"); lines != 2 {
+		errorMsg := fmt.Sprintf("Expected 2 lines, received: %d", lines)
+		t.Error(errorMsg)
+	}
+}
@@ -0,0 +1,64 @@
+package parser
+
+// Density is the note density of a chart, as drawn by density graphs.
+//
+// Jumps and hands count as a single note, matching Simply Love.
+type Density struct {
+	PerMeasure  []float64 `json:"per_measure"`
+	PerSecond   []int     `json:"per_second"`
+	PeakNPS     float64   `json:"peak_nps"`
+	PeakMeasure int       `json:"peak_measure"`
+	PeakSeconds float64   `json:"peak_seconds"`
+}
+
+// maxChartSeconds caps the per-second buckets of density and difficulty, so timing with a tiny
+// BPM cannot make them grow without bound. Later notes count in the last second.
+const maxChartSeconds = 2 * 60 * 60
+
+// secondBucket returns the per-second bucket of a time, from 0 to maxChartSeconds-1.
+//
+// Raw => 12.7
+// Parsed => 12
+func secondBucket(seconds float64) int {
+	switch {
+	case !(seconds > 0):
+		return 0
+	case seconds >= maxChartSeconds:
+		return maxChartSeconds - 1
+	}
+	return int(seconds)
+}
+
+// chartDensity calculates notes per second for each measure and for each second of the song.
+//
+// PerMeasure[m] => notes in measure m / length of measure m in seconds
+// PerSecond[s] => notes hit in the interval [s, s+1) of song time, up to maxChartSeconds
+func chartDensity(chart Chart, timing Timing) Density {
+	density := Density{PerMeasure: make([]float64, len(chart.Notes)), PerSecond: []int{}}
+	notes := make([]int, len(chart.Notes))
+	for row := range timedRows(chart, timing, Hits) {
+		notes[row.Measure]++
+
+		second := secondBucket(row.Seconds)
+		for len(density.PerSecond) <= second {
+			density.PerSecond = append(density.PerSecond, 0)
+		}
+		density.PerSecond[second]++
+	}
+
+	for m, count := range notes {
+		start := timing.Seconds(calcBeat(m, 0, 0))
+		length := timing.Seconds(calcBeat(m+1, 0, 0)) - start
+		if count == 0 || length <= 0 {
+			continue
+		}
+		nps := float64(count) / length
+		density.PerMeasure[m] = nps
+		if nps > density.PeakNPS {
+			density.PeakNPS = nps
+			density.PeakMeasure = m
+			density.PeakSeconds = start
+		}
+	}
+	return density
+}
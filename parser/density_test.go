@@ -0,0 +1,56 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestChartDensity(t *testing.T) {
+	timing := NewTiming(Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 120}}})
+	chart := Chart{Notes: noteData("1000010000100001,10000000,0000")}
+	density := chartDensity(chart, timing)
+
+	var measures = []float64{2.0, 0.5, 0.0}
+	for i, nps := range measures {
+		if math.Abs(density.PerMeasure[i]-nps) > 1e-9 {
+			errorMsg := fmt.Sprintf("Expected %f NPS in measure %d, received: %f", nps, i, density.PerMeasure[i])
+			t.Error(errorMsg)
+		}
+	}
+
+	var seconds = []int{2, 2, 1}
+	if len(density.PerSecond) != len(seconds) {
+		t.Fatal(fmt.Sprintf("Expected %d seconds, received: %d", len(seconds), len(density.PerSecond)))
+	}
+	for i, notes := range seconds {
+		if density.PerSecond[i] != notes {
+			errorMsg := fmt.Sprintf("Expected %d notes in second %d, received: %d", notes, i, density.PerSecond[i])
+			t.Error(errorMsg)
+		}
+	}
+
+	if density.PeakNPS != 2.0 || density.PeakMeasure != 0 || density.PeakSeconds != 0 {
+		t.Error("Peak NPS located incorrectly.")
+	}
+}
+
+func TestChartDensityTinyBPM(t *testing.T) {
+	// Measure 1 starts 2.4 million seconds in, which must not allocate a bucket per second.
+	timing := NewTiming(Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 0.0001}}})
+	density := chartDensity(Chart{Notes: noteData("1000,1000")}, timing)
+	if len(density.PerSecond) != maxChartSeconds || density.PerSecond[maxChartSeconds-1] != 1 {
+		errorMsg := fmt.Sprintf("Expected %d seconds with the late note in the last, received: %d", maxChartSeconds, len(density.PerSecond))
+		t.Error(errorMsg)
+	}
+}
+
+func TestChartDensityCountsJumpsOnce(t *testing.T) {
+	timing := NewTiming(Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 60}}})
+	chart := Chart{Notes: noteData("1001M000300000F0")}
+	density := chartDensity(chart, timing)
+	if density.PerMeasure[0] != 0.25 {
+		errorMsg := fmt.Sprintf("Expected 0.25 NPS, received: %f", density.PerMeasure[0])
+		t.Error(errorMsg)
+	}
+}
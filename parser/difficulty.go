@@ -0,0 +1,195 @@
+package parser
+
+import (
+	"math"
+	"sort"
+)
+
+// Difficulty is a rating computed from the note data, independent of the authored Meter.
+//
+// Ratings are roughly on the ITG meter scale, with one sub-rating per skillset.
+type Difficulty struct {
+	Overall    float64 `json:"overall"`
+	Stream     float64 `json:"stream"`
+	Jumpstream float64 `json:"jumpstream"`
+	Handstream float64 `json:"handstream"`
+	Stamina    float64 `json:"stamina"`
+	Jackspeed  float64 `json:"jackspeed"`
+	Chordjack  float64 `json:"chordjack"`
+	Technical  float64 `json:"technical"`
+}
+
+// Difficulty estimation parameters.
+const (
+	// meterScale converts effective notes per second to a meter.
+	meterScale = 1.2
+	// hardestShare is the share of the hardest seconds averaged into a rating.
+	hardestShare = 0.1
+	// staminaSeconds is the length of the section used for the Stamina rating.
+	staminaSeconds = 60
+)
+
+// intervalSkills holds the effective notes per second of each skillset within one second of a chart.
+type intervalSkills struct {
+	stream     float64
+	jumpstream float64
+	handstream float64
+	jackspeed  float64
+	chordjack  float64
+	technical  float64
+}
+
+// hardest returns the highest skillset value of an interval.
+func (s intervalSkills) hardest() float64 {
+	return math.Max(math.Max(math.Max(s.stream, s.jumpstream), math.Max(s.handstream, s.jackspeed)),
+		math.Max(s.chordjack, s.technical))
+}
+
+// estimateDifficulty rates a chart in the spirit of Etterna's MSD.
+//
+// The chart is split into one second intervals, and each interval gets an effective NPS for every
+// skillset. A skillset's rating is the mean of its hardest intervals; Stamina is the mean of the
+// hardest minute. Step.Feet must be set by SolveParity for the Technical rating.
+func estimateDifficulty(chart Chart, timing Timing) Difficulty {
+	intervals := skillIntervals(noteRows(chart), timing)
+	if len(intervals) == 0 {
+		return Difficulty{}
+	}
+
+	rate := func(value func(intervalSkills) float64) float64 {
+		values := make([]float64, len(intervals))
+		for i, interval := range intervals {
+			values[i] = value(interval)
+		}
+		return meterScale * hardestMean(values)
+	}
+	difficulty := Difficulty{
+		Stream:     rate(func(s intervalSkills) float64 { return s.stream }),
+		Jumpstream: rate(func(s intervalSkills) float64 { return s.jumpstream }),
+		Handstream: rate(func(s intervalSkills) float64 { return s.handstream }),
+		Jackspeed:  rate(func(s intervalSkills) float64 { return s.jackspeed }),
+		Chordjack:  rate(func(s intervalSkills) float64 { return s.chordjack }),
+		Technical:  rate(func(s intervalSkills) float64 { return s.technical }),
+	}
+
+	hardest := make([]float64, len(intervals))
+	for i, interval := range intervals {
+		hardest[i] = interval.hardest()
+	}
+	difficulty.Stamina = meterScale * hardestSection(hardest, staminaSeconds)
+
+	difficulty.Overall = math.Max(math.Max(math.Max(difficulty.Stream, difficulty.Jumpstream),
+		math.Max(difficulty.Handstream, difficulty.Stamina)),
+		math.Max(math.Max(difficulty.Jackspeed, difficulty.Chordjack), difficulty.Technical))
+	return difficulty
+}
+
+// skillIntervals counts the rows in each second from the first note, up to maxChartSeconds,
+// and converts them to effective notes per second for each skillset.
+func skillIntervals(rows []noteRow, timing Timing) []intervalSkills {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	type counts struct {
+		rows, notes, singles, jumps, hands, jacks, chordjacks, tech float64
+	}
+	start := timing.Seconds(rows[0].beat)
+	buckets := []counts{}
+	for i, row := range rows {
+		second := secondBucket(timing.Seconds(row.beat) - start)
+		for len(buckets) <= second {
+			buckets = append(buckets, counts{})
+		}
+		bucket := &buckets[second]
+
+		bucket.rows++
+		bucket.notes += float64(len(row.panels))
+		switch len(row.panels) {
+		case 1:
+			bucket.singles++
+		case 2:
+			bucket.jumps++
+		default:
+			bucket.hands++
+		}
+		if i > 0 && sharesPanel(rows[i-1], row) {
+			bucket.jacks++
+			if len(row.panels) > 1 {
+				bucket.chordjacks += float64(len(row.panels))
+			}
+		}
+		if isTechRow(rows, i) {
+			bucket.tech++
+		}
+	}
+
+	intervals := make([]intervalSkills, len(buckets))
+	for i, b := range buckets {
+		if b.rows == 0 {
+			continue
+		}
+		intervals[i] = intervalSkills{
+			stream:     b.singles,
+			jumpstream: b.notes * math.Min(1, 3*b.jumps/b.rows),
+			handstream: b.notes * math.Min(1, 4*b.hands/b.rows),
+			jackspeed:  2 * b.jacks,
+			chordjack:  1.5 * b.chordjacks,
+			technical:  b.rows * math.Min(1, 4*b.tech/b.rows),
+		}
+	}
+	return intervals
+}
+
+// isTechRow reports whether the feet SolveParity assigned to a row cross over, switch feet on a
+// panel or bracket.
+func isTechRow(rows []noteRow, i int) bool {
+	row := rows[i]
+	left, right := 0, 0
+	for j, foot := range row.feet {
+		switch {
+		case foot == footLeft && row.panels[j] == panelRight, foot == footRight && row.panels[j] == panelLeft:
+			return true
+		case foot == footLeft:
+			left++
+		case foot == footRight:
+			right++
+		}
+		if i > 0 {
+			prev := rows[i-1]
+			for k, panel := range prev.panels {
+				if panel == row.panels[j] && prev.feet[k] != foot && foot != footNone {
+					return true
+				}
+			}
+		}
+	}
+	return left == 2 || right == 2
+}
+
+// hardestMean returns the mean of the hardest share of the values.
+func hardestMean(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+	n := int(math.Ceil(hardestShare * float64(len(sorted))))
+	sum := 0.0
+	for _, value := range sorted[:n] {
+		sum += value
+	}
+	return sum / float64(n)
+}
+
+// hardestSection returns the highest mean of the values over any run of the given length.
+//
+// Shorter charts are averaged over the given length, so they rate lower.
+func hardestSection(values []float64, length int) float64 {
+	sum, best := 0.0, 0.0
+	for i, value := range values {
+		sum += value
+		if i >= length {
+			sum -= values[i-length]
+		}
+		best = math.Max(best, sum)
+	}
+	return best / float64(length)
+}
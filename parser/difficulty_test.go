@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// difficultyChart repeats a measure of notes and solves its parity.
+func difficultyChart(measure string, count int, timing Timing) Chart {
+	measures := make([]string, count)
+	for i := range measures {
+		measures[i] = measure
+	}
+	chart := Chart{Notes: noteData(strings.Join(measures, ","))}
+	SolveParity(chart.Notes, timing)
+	return chart
+}
+
+func TestTableEstimateDifficulty(t *testing.T) {
+	timing := NewTiming(Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 150}}})
+	var tests = []struct {
+		measure string
+		skill   func(Difficulty) float64
+		name    string
+	}{
+		{"1000010000100001010000101000000101000010100000010100001010000001", func(d Difficulty) float64 { return d.Stream }, "stream"},
+		{"1001010000101000011000101000000101000110100000010100001010010001", func(d Difficulty) float64 { return d.Jumpstream }, "jumpstream"},
+		{"1101010000101000011100101000000101001110100000010100001011010001", func(d Difficulty) float64 { return d.Handstream }, "handstream"},
+		{"1000000010000000100000001000000010000000100000001000000010000000", func(d Difficulty) float64 { return d.Jackspeed }, "jackspeed"},
+		{"1100000011000000110000001100000011000000110000001100000011000000", func(d Difficulty) float64 { return d.Chordjack }, "chordjack"},
+	}
+
+	for _, test := range tests {
+		difficulty := estimateDifficulty(difficultyChart(test.measure, 8, timing), timing)
+		if output := test.skill(difficulty); output <= 0 || output != difficulty.Overall {
+			errorMsg := fmt.Sprintf("Expected %s to rate highest, received: %+v", test.name, difficulty)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestEstimateDifficultyScalesWithSpeed(t *testing.T) {
+	stream := "1000010000100001010000101000000101000010100000010100001010000001"
+	slow := NewTiming(Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 120}}})
+	fast := NewTiming(Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 180}}})
+	slowDifficulty := estimateDifficulty(difficultyChart(stream, 16, slow), slow)
+	fastDifficulty := estimateDifficulty(difficultyChart(stream, 16, fast), fast)
+	if fastDifficulty.Overall <= slowDifficulty.Overall {
+		errorMsg := fmt.Sprintf("Expected faster stream to rate higher, received: %f and %f", slowDifficulty.Overall, fastDifficulty.Overall)
+		t.Error(errorMsg)
+	}
+	if fastDifficulty.Stamina >= fastDifficulty.Stream {
+		t.Error("Stamina should rate below Stream for a chart shorter than a minute.")
+	}
+}
+
+func TestEstimateDifficultyEmpty(t *testing.T) {
+	if difficulty := estimateDifficulty(Chart{Notes: noteData("0000")}, NewTiming(Header{})); difficulty != (Difficulty{}) {
+		t.Error("Chart without notes should have an empty Difficulty.")
+	}
+}
+
+func TestEstimateDifficultyTinyBPM(t *testing.T) {
+	timing := NewTiming(Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 0.0001}}})
+	chart := difficultyChart("1000", 2, timing)
+	if intervals := skillIntervals(noteRows(chart), timing); len(intervals) != maxChartSeconds {
+		errorMsg := fmt.Sprintf("Expected %d intervals, received: %d", maxChartSeconds, len(intervals))
+		t.Error(errorMsg)
+	}
+}
+
+func TestHardestSection(t *testing.T) {
+	if output := hardestSection([]float64{1, 5, 5, 1}, 2); output != 5 {
+		errorMsg := fmt.Sprintf("Expected 5, received: %f", output)
+		t.Error(errorMsg)
+	}
+	if output := hardestSection([]float64{4}, 2); output != 2 {
+		errorMsg := fmt.Sprintf("Expected 2, received: %f", output)
+		t.Error(errorMsg)
+	}
+}
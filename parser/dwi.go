@@ -0,0 +1,217 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dwiDifficulties maps DWI difficulty names to StepMania difficulties.
+var dwiDifficulties = map[string]string{
+	"BEGINNER": "Beginner",
+	"BASIC":    "Easy",
+	"ANOTHER":  "Medium",
+	"MANIAC":   "Hard",
+	"SMANIAC":  "Challenge",
+}
+
+// dwiPanels maps DWI step characters to the panels they press, one bit per column in L, D, U, R order.
+var dwiPanels = map[byte]uint8{
+	'0': 0, '1': 0x3, '2': 0x2, '3': 0xa, '4': 0x1, '5': 0,
+	'6': 0x8, '7': 0x5, '8': 0x4, '9': 0xc, 'A': 0x6, 'B': 0x9,
+}
+
+// dwiSpacing maps DWI quantization brackets to the 192nds of a measure between steps.
+// Steps outside brackets are 8th notes.
+var dwiSpacing = map[byte]int{
+	'(': 12, ')': 24,
+	'[': 8, ']': 24,
+	'{': 3, '}': 24,
+	'`': 1, '\'': 24,
+}
+
+// ParseDWI parses the header tags and charts of a .dwi file into a Simfile.
+//
+// #SINGLE charts become dance-single charts. Other styles are kept as empty charts, like
+// charts other than dance-single in a .sm file.
+func ParseDWI(data []byte) (Simfile, error) {
+	return ParseDWIWith(data, ParseOptions{})
+}
+
+// ParseDWIWith parses a .dwi file like ParseDWI.
+//
+// Notes are always decoded, since DecodeNotes only reads .sm note data.
+func ParseDWIWith(data []byte, options ParseOptions) (sim Simfile, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			sim, err = Simfile{}, fmt.Errorf("Parse Error: %v", r)
+		}
+	}()
+
+	sim.SchemaVersion = SchemaVersion
+
+	bpm := []BeatChange{{Beat: 0}}
+	changes := []BeatChange{}
+	charts := [][]string{}
+	scanner := NewScanner(data)
+	for scanner.Next() {
+		value := string(scanner.Value())
+		switch string(scanner.Name()) {
+		case "TITLE":
+			sim.Header.Title = strings.TrimSpace(value)
+		case "ARTIST":
+			sim.Header.Artist = strings.TrimSpace(value)
+		case "GENRE":
+			sim.Header.Genre = strings.TrimSpace(value)
+		case "CDTITLE":
+			sim.Header.CDTitle = strings.TrimSpace(value)
+		case "FILE":
+			sim.Header.Music = strings.TrimSpace(value)
+		case "BPM":
+			bpm[0].Value = dwiFloat(value)
+		case "GAP":
+			sim.Header.Offset = -dwiFloat(value) / 1000
+		case "SAMPLESTART":
+			sim.Header.SampleStart = dwiSeconds(value)
+		case "SAMPLELENGTH":
+			sim.Header.SampleLength = dwiSeconds(value)
+		case "DISPLAYBPM":
+			sim.Header.DisplayBPM = displayBPM(strings.Replace(strings.TrimSpace(value), "..", ":", 1))
+		case "CHANGEBPM":
+			changes = append(changes, dwiBeatChanges(value, 1)...)
+		case "FREEZE":
+			sim.Header.Stops = append(sim.Header.Stops, dwiBeatChanges(value, 1000)...)
+		case "SINGLE", "DOUBLE", "COUPLE", "SOLO":
+			charts = append(charts, append([]string{string(scanner.Name())}, strings.Split(value, ":")...))
+		}
+	}
+	sim.Header.BPMs = append(bpm, changes...)
+
+	for _, fields := range charts {
+		if fields[0] != "SINGLE" {
+			sim.Charts = append(sim.Charts, Chart{})
+			continue
+		}
+		if len(fields) < 4 {
+			panic(fmt.Sprintf("#SINGLE needs a difficulty, meter and steps, got %q", strings.Join(fields[1:], ":")))
+		}
+		chart := Chart{Type: "dance-single", Difficulty: dwiDifficulties[strings.ToUpper(strings.TrimSpace(fields[1]))]}
+		chart.Meter, err = strconv.Atoi(strings.TrimSpace(fields[2]))
+		CheckError(err)
+		chart.Notes = dwiMeasures(fields[3])
+		chart.Analysis = Analyze(chart, sim.Header)
+		sim.Charts = append(sim.Charts, chart)
+	}
+	return sim, nil
+}
+
+// dwiFloat parses a DWI number, panicking when it is malformed.
+func dwiFloat(value string) float64 {
+	number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	CheckError(err)
+	return number
+}
+
+// dwiSeconds parses a DWI time, either in seconds or as minutes and seconds.
+//
+// Raw => "1:05.5"
+// Parsed => 65.5
+func dwiSeconds(value string) float64 {
+	seconds := 0.0
+	for _, part := range strings.Split(strings.TrimSpace(value), ":") {
+		seconds = seconds*60 + dwiFloat(part)
+	}
+	return seconds
+}
+
+// dwiBeatChanges parses #CHANGEBPM or #FREEZE values positioned in 16th notes, dividing each
+// value by scale.
+//
+// Raw => "64=200,128=1000" (scale 1000)
+// Parsed => [{16 0.2} {32 1}]
+func dwiBeatChanges(value string, scale float64) []BeatChange {
+	changes := []BeatChange{}
+	for _, change := range strings.Split(scannedList([]byte(value)), ",") {
+		if change == "" {
+			continue
+		}
+		beat, changeValue, found := strings.Cut(change, "=")
+		if !found {
+			panic(fmt.Sprintf("malformed beat change %q", change))
+		}
+		changes = append(changes, BeatChange{Beat: dwiFloat(beat) / 4, Value: dwiFloat(changeValue) / scale})
+	}
+	return changes
+}
+
+// dwiMeasures decodes the steps of a DWI chart into Measures.
+//
+// A step followed by "!" and a second step starts holds on the panels of the second step. The
+// next step on a held panel ends the hold. Steps between "<" and ">" are hit together.
+func dwiMeasures(steps string) []Measure {
+	rows := []Row{}
+	held := uint8(0)
+	index, spacing := 0, 24
+	for i := 0; i < len(steps); i++ {
+		char := steps[i]
+		if next, ok := dwiSpacing[char]; ok {
+			spacing = next
+			continue
+		}
+		if char <= ' ' {
+			continue
+		}
+
+		pressed, holds := uint8(0), uint8(0)
+		if char == '<' {
+			for i++; i < len(steps) && steps[i] != '>'; i++ {
+				if steps[i] > ' ' {
+					panels, heads := dwiStep(steps, &i)
+					pressed, holds = pressed|panels, holds|heads
+				}
+			}
+		} else {
+			pressed, holds = dwiStep(steps, &i)
+		}
+
+		row := Row{Index: int32(index)}
+		for column := 0; column < 4; column++ {
+			bit := uint8(1) << uint(column)
+			switch {
+			case pressed&bit == 0:
+			case held&bit != 0:
+				row.SetKind(column, NoteTail)
+				held &^= bit
+			case holds&bit != 0:
+				row.SetKind(column, NoteHoldHead)
+				held |= bit
+			default:
+				row.SetKind(column, NoteTap)
+			}
+		}
+		if !row.IsEmpty() {
+			rows = append(rows, row)
+		}
+		index += spacing
+	}
+
+	return rowMeasures(rows, index, 4)
+}
+
+// dwiStep reads the step at steps[*i] and any "!" hold that follows it, leaving *i on the last
+// character read. It returns the panels pressed and the panels starting holds.
+func dwiStep(steps string, i *int) (uint8, uint8) {
+	pressed, ok := dwiPanels[steps[*i]]
+	if !ok {
+		panic(fmt.Sprintf("unknown DWI step %q", steps[*i]))
+	}
+	if *i+2 >= len(steps) || steps[*i+1] != '!' {
+		return pressed, 0
+	}
+	holds, ok := dwiPanels[steps[*i+2]]
+	if !ok {
+		panic(fmt.Sprintf("unknown DWI hold %q", steps[*i+2]))
+	}
+	*i += 2
+	return pressed, holds & pressed
+}
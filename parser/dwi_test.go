@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testDWI = `#TITLE:DWI Song;
+#ARTIST:DWI Artist;
+#BPM:120.000;
+#GAP:250;
+#CHANGEBPM:64=240;
+#FREEZE:32=500;
+#SAMPLESTART:1:05.5;
+#DISPLAYBPM:120..240;
+// Comments are skipped.
+#SINGLE:BASIC:3:
+2468<28>000
+(2!24002)0000
+[2468]00
+{8888}00000000
+;
+#DOUBLE:MANIAC:9:0000:0000;
+`
+
+func TestParseDWI(t *testing.T) {
+	sim, err := ParseDWI([]byte(testDWI))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := sim.Header
+	if header.Title != "DWI Song" || header.Artist != "DWI Artist" || header.Offset != -0.25 || header.SampleStart != 65.5 {
+		errorMsg := fmt.Sprintf("DWI header parsed incorrectly: %+v", header)
+		t.Error(errorMsg)
+	}
+	if bpms := []BeatChange{{0, 120}, {16, 240}}; !reflect.DeepEqual(header.BPMs, bpms) {
+		errorMsg := fmt.Sprintf("Expected BPMs %v, received: %v", bpms, header.BPMs)
+		t.Error(errorMsg)
+	}
+	if stops := []BeatChange{{8, 0.5}}; !reflect.DeepEqual(header.Stops, stops) {
+		errorMsg := fmt.Sprintf("Expected stops %v, received: %v", stops, header.Stops)
+		t.Error(errorMsg)
+	}
+	if !reflect.DeepEqual(header.DisplayBPM, []float64{120, 240}) {
+		errorMsg := fmt.Sprintf("Expected display BPM [120 240], received: %v", header.DisplayBPM)
+		t.Error(errorMsg)
+	}
+
+	if len(sim.Charts) != 2 || sim.Charts[1].Type != "" {
+		t.Fatal("DWI doubles chart not kept as an empty chart.")
+	}
+	chart := sim.Charts[0]
+	if chart.Type != "dance-single" || chart.Difficulty != "Easy" || chart.Meter != 3 {
+		errorMsg := fmt.Sprintf("DWI chart header parsed incorrectly: %s %s %d", chart.Type, chart.Difficulty, chart.Meter)
+		t.Error(errorMsg)
+	}
+	if notes := Summarize(chart, sim.Header).Notes; notes != 15 {
+		errorMsg := fmt.Sprintf("Expected 15 notes, received: %d", notes)
+		t.Error(errorMsg)
+	}
+}
+
+func TestDWIMeasures(t *testing.T) {
+	var tests = []struct {
+		steps         string
+		quantizations []int
+		rows          []string
+	}{
+		{"2468<28>000", []int{8}, []string{"0100", "1000", "0001", "0010", "0110"}},
+		{"(2!24002)0000", []int{16}, []string{"0200", "1000", "0300"}},
+		{"[2468]00", []int{24}, []string{"0100", "1000", "0001", "0010"}},
+		{"00{8888}0000 0", []int{64}, []string{"0010", "0010", "0010", "0010"}},
+		{"`2'0000000 <4!46!6>0000000", []int{192, 4}, []string{"0100", "2002"}},
+	}
+
+	for _, test := range tests {
+		measures := dwiMeasures(test.steps)
+		quantizations := []int{}
+		rows := []string{}
+		for _, measure := range measures {
+			quantizations = append(quantizations, measure.Quantization)
+			for _, step := range measure.Steps {
+				if row := strings.Join(step.Panels(), ""); row != "0000" {
+					rows = append(rows, row)
+				}
+			}
+		}
+		if !reflect.DeepEqual(quantizations, test.quantizations) || !reflect.DeepEqual(rows, test.rows) {
+			errorMsg := fmt.Sprintf("Expected %v %v for %q, received: %v %v", test.quantizations, test.rows, test.steps, quantizations, rows)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestParseDWIErrors(t *testing.T) {
+	var tests = []string{
+		"#BPM:fast;",
+		"#SINGLE:BASIC:3:2468Z;",
+		"#SINGLE:BASIC:three:2468;",
+		"#SINGLE:BASIC;",
+		"#FREEZE:32;",
+	}
+
+	for _, test := range tests {
+		if _, err := ParseDWI([]byte(test)); err == nil || !strings.HasPrefix(err.Error(), "Parse Error") {
+			errorMsg := fmt.Sprintf("Expected a parse error for %q, received: %v", test, err)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestParseFSDWI(t *testing.T) {
+	fsys := fstest.MapFS{
+		"pack/song/song.DWI": {Data: []byte(testDWI)},
+		"pack/song/song.txt": {Data: []byte(testDWI)},
+	}
+	sim, err := ParseFS(fsys, "pack/song/song.DWI")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim.SongPack != "pack" || sim.Header.Title != "DWI Song" {
+		t.Error("ParseFS did not parse the .dwi file.")
+	}
+	if _, err := ParseFS(fsys, "pack/song/song.txt"); err == nil {
+		t.Error("ParseFS did not return an error for a .txt file.")
+	}
+	if !Parsable("song.dwi") || Parsable("song.ssc") {
+		t.Error("Parsable reported the wrong formats.")
+	}
+}
@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// GrooveStatsHash returns the chart hash used by GrooveStats to identify a chart.
+//
+// It is the first 16 hex characters of the SHA1 of the minimized note data followed by the
+// BPMs rounded to 3 decimals, as computed by Simply Love.
+func GrooveStatsHash(chart Chart, header Header) string {
+	measures := make([]string, len(chart.Notes))
+	for i, measure := range chart.Notes {
+		measures[i] = strings.Join(minimizeMeasure(stepRows(measure.Steps)), "\n")
+	}
+	data := strings.Join(measures, "\n,\n") + grooveStatsBPMs(header.BPMs)
+	sum := sha1.Sum([]byte(data))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// ChartKey returns an Etterna-style ChartKey for a chart.
+//
+// Every row with a note contributes its per-column note types and the integer BPM at that row
+// to a string, and the key is "X" followed by its SHA1.
+func ChartKey(chart Chart, header Header) string {
+	timing := NewTiming(header)
+	var key strings.Builder
+	for _, measure := range chart.Notes {
+		for _, step := range measure.Steps {
+			types := ""
+			empty := true
+			for _, panel := range step.Panels() {
+				noteType := etternaNoteType(panel)
+				if noteType != 0 {
+					empty = false
+				}
+				types += strconv.Itoa(noteType)
+			}
+			if empty {
+				continue
+			}
+			key.WriteString(types)
+			key.WriteString(strconv.Itoa(int(timing.BPM(step.Beat) + 0.374643)))
+		}
+	}
+	sum := sha1.Sum([]byte(key.String()))
+	return "X" + hex.EncodeToString(sum[:])
+}
+
+// stepRows returns the note rows of a measure as they appear in the simfile.
+func stepRows(steps []Step) []string {
+	rows := make([]string, len(steps))
+	for i, step := range steps {
+		rows[i] = strings.Join(step.Panels(), "")
+	}
+	return rows
+}
+
+// minimizeMeasure removes every other row while those rows are empty.
+//
+// Raw => [1000 0000 0100 0000]
+// Minimized => [1000 0100]
+func minimizeMeasure(rows []string) []string {
+	for len(rows)%2 == 0 && len(rows) > 0 {
+		for i := 1; i < len(rows); i += 2 {
+			if strings.Trim(rows[i], "0") != "" {
+				return rows
+			}
+		}
+		halved := make([]string, len(rows)/2)
+		for i := range halved {
+			halved[i] = rows[2*i]
+		}
+		rows = halved
+	}
+	return rows
+}
+
+// grooveStatsBPMs formats the BPMs with 3 decimals, rounding halves up.
+//
+// Raw => [{0 182.2} {136 91.1}]
+// Parsed => "0.000=182.200,136.000=91.100"
+func grooveStatsBPMs(bpms []BeatChange) string {
+	round := func(value float64) float64 {
+		return math.Floor(value*1000+0.5) / 1000
+	}
+	parts := make([]string, len(bpms))
+	for i, bpm := range bpms {
+		parts[i] = fmt.Sprintf("%.3f=%.3f", round(bpm.Beat), round(bpm.Value))
+	}
+	return strings.Join(parts, ",")
+}
+
+// etternaNoteType maps a step value to StepMania's TapNoteType.
+func etternaNoteType(value string) int {
+	switch value {
+	case "1":
+		return 1
+	case "2", "4":
+		return 2
+	case "M":
+		return 4
+	case "L":
+		return 5
+	case "F":
+		return 8
+	}
+	return 0
+}
@@ -0,0 +1,69 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGrooveStatsHash(t *testing.T) {
+	header := Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 120}, BeatChange{Beat: 4, Value: 150.5}}}
+	chart := Chart{Notes: noteData("1000000001000000,0001")}
+	if output := GrooveStatsHash(chart, header); output != "aed821dbc8e5d4b2" {
+		errorMsg := fmt.Sprintf("Expected aed821dbc8e5d4b2, received: %s", output)
+		t.Error(errorMsg)
+	}
+
+	// The same chart written at a higher quantization hashes the same.
+	chart = Chart{Notes: noteData("10000000000000000100000000000000,0001")}
+	if output := GrooveStatsHash(chart, header); output != "aed821dbc8e5d4b2" {
+		errorMsg := fmt.Sprintf("Expected aed821dbc8e5d4b2 for the unminimized chart, received: %s", output)
+		t.Error(errorMsg)
+	}
+}
+
+func TestChartKey(t *testing.T) {
+	header := Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 120}, BeatChange{Beat: 4, Value: 150.5}}}
+	chart := Chart{Notes: noteData("1000000001000000,0001")}
+	expected := "Xbfa5c33c7ce9319cc36b295d6a245e233a5f3de4"
+	if output := ChartKey(chart, header); output != expected {
+		errorMsg := fmt.Sprintf("Expected %s, received: %s", expected, output)
+		t.Error(errorMsg)
+	}
+}
+
+func TestTableMinimizeMeasure(t *testing.T) {
+	var tests = []struct {
+		rows      []string
+		minimized []string
+	}{
+		{[]string{"1000", "0000", "0100", "0000"}, []string{"1000", "0100"}},
+		{[]string{"1000", "0000", "0000", "0000"}, []string{"1000"}},
+		{[]string{"1000", "0010", "0100", "0000"}, []string{"1000", "0010", "0100", "0000"}},
+		{[]string{"1000", "0000", "0M00"}, []string{"1000", "0000", "0M00"}},
+	}
+
+	for _, test := range tests {
+		if output := minimizeMeasure(test.rows); fmt.Sprint(output) != fmt.Sprint(test.minimized) {
+			errorMsg := fmt.Sprintf("Expected %v, received: %v", test.minimized, output)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestTableGrooveStatsBPMs(t *testing.T) {
+	var tests = []struct {
+		bpms   []BeatChange
+		result string
+	}{
+		{nil, ""},
+		{[]BeatChange{BeatChange{Beat: 0, Value: 182.2}}, "0.000=182.200"},
+		{[]BeatChange{BeatChange{Beat: 0, Value: 120.0005}, BeatChange{Beat: 136, Value: 91.1}}, "0.000=120.001,136.000=91.100"},
+	}
+
+	for _, test := range tests {
+		if output := grooveStatsBPMs(test.bpms); output != test.result {
+			errorMsg := fmt.Sprintf("Expected %s, received: %s", test.result, output)
+			t.Error(errorMsg)
+		}
+	}
+}
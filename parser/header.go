@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"bytes"
 	"strconv"
 	"strings"
 )
@@ -27,6 +28,7 @@ type Header struct {
 	DisplayBPM       []float64    `json:"display_bpm"`
 	BPMs             []BeatChange `json:"bpms"`
 	Stops            []BeatChange `json:"stops"`
+	Delays           []BeatChange `json:"delays"`
 	BGChanges        []BeatChange `json:"bg_changes"`
 	KeySounds        []BeatChange `json:"keysounds"`
 }
@@ -83,6 +85,9 @@ func ExtractHeader(tag string, sim Simfile) Simfile {
 	case lineContains(tag, "#STOPS:"):
 		stopString := tagValue(tag)
 		sim.Header.Stops = extractBeatChanges(stopString)
+	case lineContains(tag, "#DELAYS:"):
+		delayString := tagValue(tag)
+		sim.Header.Delays = extractBeatChanges(delayString)
 	case lineContains(tag, "#BGCHANGES:"):
 		bgChangeString := tagValue(tag)
 		sim.Header.BGChanges = extractBeatChanges(bgChangeString)
@@ -97,6 +102,78 @@ func ExtractHeader(tag string, sim Simfile) Simfile {
 	return sim
 }
 
+// extractHeaderTag parses a Header tag read by a Scanner.
+func extractHeaderTag(name []byte, value []byte, sim Simfile) Simfile {
+	switch string(name) {
+	case "TITLE":
+		sim.Header.Title = scannedValue(value)
+	case "SUBTITLE":
+		sim.Header.Subtitle = scannedValue(value)
+	case "ARTIST":
+		sim.Header.Artist = scannedValue(value)
+	case "TITLETRANSLIT":
+		sim.Header.TitleTranslit = scannedValue(value)
+	case "SUBTITLETRANSLIT":
+		sim.Header.SubtitleTranslit = scannedValue(value)
+	case "ARTISTTRANSLIT":
+		sim.Header.ArtistTranslit = scannedValue(value)
+	case "GENRE":
+		sim.Header.Genre = scannedValue(value)
+	case "CREDIT":
+		sim.Header.Credit = scannedValue(value)
+	case "BANNER":
+		sim.Header.Banner = scannedValue(value)
+	case "BACKGROUND":
+		sim.Header.Background = scannedValue(value)
+	case "LYRICSPATH":
+		sim.Header.LyricsPath = scannedValue(value)
+	case "CDTITLE":
+		sim.Header.CDTitle = scannedValue(value)
+	case "MUSIC":
+		sim.Header.Music = scannedValue(value)
+	case "OFFSET":
+		sim.Header.Offset, _ = strconv.ParseFloat(scannedValue(value), 64)
+	case "SAMPLESTART":
+		sim.Header.SampleStart, _ = strconv.ParseFloat(scannedValue(value), 64)
+	case "SAMPLELENGTH":
+		sim.Header.SampleLength, _ = strconv.ParseFloat(scannedValue(value), 64)
+	case "SELECTABLE":
+		sim.Header.Selectable = scannedValue(value)
+	case "DISPLAYBPM":
+		sim.Header.DisplayBPM = displayBPM(strings.TrimSpace(string(value)))
+	case "BPMS":
+		sim.Header.BPMs = extractBeatChanges(scannedList(value))
+	case "STOPS":
+		sim.Header.Stops = extractBeatChanges(scannedList(value))
+	case "DELAYS":
+		sim.Header.Delays = extractBeatChanges(scannedList(value))
+	case "BGCHANGES":
+		sim.Header.BGChanges = extractBeatChanges(scannedList(value))
+	case "KEYSOUNDS":
+		sim.Header.KeySounds = extractBeatChanges(scannedList(value))
+	}
+	return sim
+}
+
+// scannedValue returns the first parameter of a tag value read by a Scanner.
+//
+// Raw => " Song Title:ignored\r\n"
+// Parsed => "Song Title"
+func scannedValue(value []byte) string {
+	if colon := bytes.IndexByte(value, ':'); colon >= 0 {
+		value = value[:colon]
+	}
+	return string(bytes.TrimSpace(value))
+}
+
+// scannedList returns a comma separated tag value read by a Scanner, without whitespace.
+//
+// Raw => "0.000=182.200\r\n,136.000=91.100"
+// Parsed => "0.000=182.200,136.000=91.100"
+func scannedList(value []byte) string {
+	return strings.Join(strings.Fields(string(value)), "")
+}
+
 // extractBeatChanges parses header tags with changes like Stops or BPMs.
 //
 // Raw => "0.000=179.000,920.000=117.073"
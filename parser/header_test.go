@@ -110,6 +110,7 @@ func TestTableExtractHeader(t *testing.T) {
 		"#DISPLAYBPM:200.000;",
 		"#BPMS:0.000=200.000;",
 		"#STOPS:;",
+		"#DELAYS:16.000=0.250;",
 		"#BGCHANGES:;",
 		"#KEYSOUNDS:;",
 		"#NOTES:;",
@@ -128,4 +129,7 @@ func TestTableExtractHeader(t *testing.T) {
 	if sim.Header.Credit != "barndoor" {
 		t.Error("Credit not parsed correctly.")
 	}
+	if len(sim.Header.Delays) != 1 || sim.Header.Delays[0] != (BeatChange{Beat: 16, Value: 0.25}) {
+		t.Error("Delays not parsed correctly.")
+	}
 }
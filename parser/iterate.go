@@ -0,0 +1,119 @@
+package parser
+
+import "iter"
+
+// RowInfo is a row of a chart yielded by Rows, with its timing.
+type RowInfo struct {
+	Measure int
+	Beat    float64
+	Seconds float64
+	// Snap is the note type of the row: 4 for quarter notes, 8 for eighths, up to 192.
+	Snap  int
+	Notes Row
+}
+
+// RowFilter selects the rows yielded by Rows.
+type RowFilter func(row RowInfo) bool
+
+// Visitor receives the rows of a chart from Walk.
+type Visitor interface {
+	// VisitRow is called for each row in time order. Returning false stops the walk.
+	VisitRow(row RowInfo) bool
+}
+
+// snaps are the note types StepMania colors, from coarsest to finest.
+var snaps = []int{4, 8, 12, 16, 24, 32, 48, 64, 192}
+
+// Rows returns an iterator over the rows of a dance-single chart in time order, keeping only
+// the rows every filter accepts.
+//
+//	for row := range parser.Rows(chart, sim.Header, parser.NonEmpty, parser.BeatRange(0, 16)) {
+//		fmt.Println(row.Beat, row.Seconds, row.Notes.Kind(0))
+//	}
+func Rows(chart Chart, header Header, filters ...RowFilter) iter.Seq[RowInfo] {
+	return timedRows(chart, NewTiming(header), filters...)
+}
+
+// Walk calls the Visitor for the rows of a chart in time order, keeping only the rows every
+// filter accepts.
+func Walk(chart Chart, header Header, visitor Visitor, filters ...RowFilter) {
+	for row := range Rows(chart, header, filters...) {
+		if !visitor.VisitRow(row) {
+			return
+		}
+	}
+}
+
+// timedRows returns an iterator over the rows of a chart using an existing Timing.
+func timedRows(chart Chart, timing Timing, filters ...RowFilter) iter.Seq[RowInfo] {
+	return func(yield func(RowInfo) bool) {
+		for _, measure := range chart.Notes {
+			for s, step := range measure.Steps {
+				row := RowInfo{
+					Measure: measure.MeasureNumber,
+					Beat:    step.Beat,
+					Snap:    rowSnap(s, measure.Quantization),
+					Notes:   stepRow(step, measure.MeasureNumber*RowsPerMeasure+s*RowsPerMeasure/measure.Quantization),
+				}
+				if !acceptRow(row, filters) {
+					continue
+				}
+				row.Seconds = timing.Seconds(row.Beat)
+				if !yield(row) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// acceptRow reports whether every filter accepts a row.
+func acceptRow(row RowInfo, filters []RowFilter) bool {
+	for _, filter := range filters {
+		if !filter(row) {
+			return false
+		}
+	}
+	return true
+}
+
+// rowSnap returns the coarsest snap a row of a measure falls on.
+//
+// Raw => row 3 of 16
+// Parsed => 16
+func rowSnap(row int, quantization int) int {
+	for _, snap := range snaps {
+		if row*snap%quantization == 0 {
+			return snap
+		}
+	}
+	return RowsPerMeasure
+}
+
+// NonEmpty is a RowFilter keeping rows with any note, including mines and hold tails.
+func NonEmpty(row RowInfo) bool {
+	return !row.Notes.IsEmpty()
+}
+
+// Hits is a RowFilter keeping rows with notes the player hits.
+func Hits(row RowInfo) bool {
+	return row.Notes.Hits() > 0
+}
+
+// Holds is a RowFilter keeping rows where a hold or roll starts or ends.
+func Holds(row RowInfo) bool {
+	for column := 0; column < 16; column++ {
+		switch row.Notes.Kind(column) {
+		case NoteHoldHead, NoteRollHead, NoteTail:
+			return true
+		}
+	}
+	return false
+}
+
+// BeatRange returns a RowFilter keeping rows with from <= beat < to.
+func BeatRange(from float64, to float64) RowFilter {
+	return func(row RowInfo) bool {
+		return row.Beat >= from && row.Beat < to
+	}
+}
@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRows(t *testing.T) {
+	header := Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 120}}}
+	chart := Chart{Notes: noteData("100000000200000000000000300000M0,00000000")}
+
+	var beats []float64
+	var seconds []float64
+	for row := range Rows(chart, header, NonEmpty) {
+		beats = append(beats, row.Beat)
+		seconds = append(seconds, row.Seconds)
+	}
+
+	var expected = []float64{0, 1, 3, 3.5}
+	if len(beats) != len(expected) {
+		t.Fatal(fmt.Sprintf("Expected %d rows, received: %d", len(expected), len(beats)))
+	}
+	for i, beat := range expected {
+		if beats[i] != beat || seconds[i] != beat/2 {
+			errorMsg := fmt.Sprintf("Expected row %d at beat %f, received: %f (%fs)", i, beat, beats[i], seconds[i])
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestRowsFilters(t *testing.T) {
+	header := Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 120}}}
+	chart := Chart{Notes: noteData("1000020000000000,3000M00000010000")}
+
+	var tests = []struct {
+		filters []RowFilter
+		rows    int
+	}{
+		{nil, 8},
+		{[]RowFilter{NonEmpty}, 5},
+		{[]RowFilter{Hits}, 3},
+		{[]RowFilter{Holds}, 2},
+		{[]RowFilter{NonEmpty, BeatRange(1, 5)}, 2},
+	}
+	for _, test := range tests {
+		rows := 0
+		for range Rows(chart, header, test.filters...) {
+			rows++
+		}
+		if rows != test.rows {
+			errorMsg := fmt.Sprintf("Expected %d rows for %d filters, received: %d", test.rows, len(test.filters), rows)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestTableRowSnap(t *testing.T) {
+	var tests = []struct {
+		row          int
+		quantization int
+		snap         int
+	}{
+		{0, 4, 4},
+		{1, 8, 8},
+		{2, 8, 4},
+		{1, 12, 12},
+		{3, 16, 16},
+		{3, 24, 8},
+		{5, 192, 192},
+		{1, 20, 192},
+	}
+	for _, test := range tests {
+		if output := rowSnap(test.row, test.quantization); output != test.snap {
+			errorMsg := fmt.Sprintf("Expected snap %d for row %d of %d, received: %d", test.snap, test.row, test.quantization, output)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+type rowCounter struct {
+	rows  int
+	limit int
+}
+
+func (c *rowCounter) VisitRow(row RowInfo) bool {
+	c.rows++
+	return c.rows < c.limit
+}
+
+func TestWalk(t *testing.T) {
+	header := Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 120}}}
+	chart := Chart{Notes: noteData("1111111111111111,1111111111111111")}
+
+	visitor := &rowCounter{limit: 5}
+	Walk(chart, header, visitor, NonEmpty)
+	if visitor.rows != 5 {
+		errorMsg := fmt.Sprintf("Expected the walk to stop after 5 rows, received: %d", visitor.rows)
+		t.Error(errorMsg)
+	}
+}
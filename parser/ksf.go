@@ -0,0 +1,269 @@
+package parser
+
+import (
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ksfDifficulties maps words in KSF file names to StepMania difficulties, checked in order.
+var ksfDifficulties = []struct {
+	word       string
+	difficulty string
+}{
+	{"nightmare", "Challenge"},
+	{"crazy", "Hard"},
+	{"hard", "Medium"},
+	{"normal", "Easy"},
+	{"easy", "Easy"},
+}
+
+// ksfTiming is the timing read from the tags of a .ksf file.
+type ksfTiming struct {
+	tickCount int
+	startTime float64
+	bpms      [3]float64
+	bunkis    [2]float64
+}
+
+// ParseKSF parses the .ksf files of a song folder in a file system into a Simfile, one chart
+// per file in name order.
+//
+// Charts are pump-single, or pump-double when the file name contains "double" or a row has
+// notes in columns 6 to 10. Their difficulty comes from the file name, as in Crazy_1.ksf, and
+// their meter from #DIFFICULTY. The header comes from the first file. Every chart of a Simfile
+// shares one timing, so a file whose #STARTTIME, BPMs or stops differ from the first file's is
+// a Parse Error.
+func ParseKSF(fsys fs.FS, dir string) (Simfile, error) {
+	return ParseKSFWith(fsys, dir, ParseOptions{})
+}
+
+// ParseKSFWith parses the .ksf files of a song folder like ParseKSF. Notes are always decoded,
+// since DecodeNotes only reads .sm note data.
+func ParseKSFWith(fsys fs.FS, dir string, options ParseOptions) (sim Simfile, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			sim, err = Simfile{}, fmt.Errorf("Parse Error: %v", r)
+		}
+	}()
+
+	names, err := ksfNames(fsys, dir)
+	if err != nil {
+		return Simfile{}, err
+	}
+
+	sim.SchemaVersion = SchemaVersion
+	for i, name := range names {
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return Simfile{}, err
+		}
+		chart, header := parseKSFChart(name, data)
+		if i == 0 {
+			sim.Header = header
+		} else if !sameTiming(header, sim.Header) {
+			panic(fmt.Sprintf("the timing of %s differs from %s", name, names[0]))
+		}
+		sim.Charts = append(sim.Charts, chart)
+	}
+	for i := range sim.Charts {
+		sim.Charts[i].Analysis = Analyze(sim.Charts[i], sim.Header)
+	}
+	return sim, nil
+}
+
+// ksfNames returns the sorted names of the .ksf files in a song folder.
+func ksfNames(fsys fs.FS, dir string) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(path.Ext(entry.Name()), ".ksf") {
+			names = append(names, entry.Name())
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("Parse Error: no .ksf files in %s", dir)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// parseKSFChart parses one .ksf file into a chart and the header it describes.
+func parseKSFChart(name string, data []byte) (Chart, Header) {
+	header := Header{}
+	timing := ksfTiming{tickCount: 4}
+	chart := Chart{Type: "pump-single", Difficulty: "Medium", Description: strings.TrimSuffix(name, path.Ext(name))}
+	lower := strings.ToLower(name)
+	for _, d := range ksfDifficulties {
+		if strings.Contains(lower, d.word) {
+			chart.Difficulty = d.difficulty
+			break
+		}
+	}
+
+	steps := ""
+	scanner := NewScanner(data)
+	for scanner.Next() {
+		value := strings.TrimSpace(string(scanner.Value()))
+		switch string(scanner.Name()) {
+		case "TITLE":
+			header.Title = value
+		case "ARTIST":
+			header.Artist = value
+		case "TICKCOUNT":
+			timing.tickCount = ksfTickCount(value)
+		case "STARTTIME":
+			timing.startTime = dwiFloat(value) / 100
+		case "BPM":
+			timing.bpms[0] = dwiFloat(value)
+		case "BPM2":
+			timing.bpms[1] = dwiFloat(value)
+		case "BPM3":
+			timing.bpms[2] = dwiFloat(value)
+		case "BUNKI":
+			timing.bunkis[0] = dwiFloat(value) / 100
+		case "BUNKI2":
+			timing.bunkis[1] = dwiFloat(value) / 100
+		case "DIFFICULTY":
+			chart.Meter = int(dwiFloat(value))
+		case "STEP":
+			steps = string(scanner.Value())
+		}
+	}
+	// KIU titles hold both the artist and the title.
+	if artist, title, found := strings.Cut(header.Title, " - "); found && header.Artist == "" {
+		header.Artist, header.Title = strings.TrimSpace(artist), strings.TrimSpace(title)
+	}
+	header.Offset = -timing.startTime
+	header.BPMs = timing.beatChanges()
+
+	columns := 5
+	if strings.Contains(lower, "double") {
+		columns = 10
+	}
+	rows, end, columns := ksfRows(steps, timing.tickCount, columns, &header)
+	if columns == 10 {
+		chart.Type = "pump-double"
+	}
+	chart.Notes = rowMeasures(rows, end, columns)
+	return chart, header
+}
+
+// sameTiming reports whether two headers read from .ksf files have the same offset, BPMs
+// and stops.
+func sameTiming(a Header, b Header) bool {
+	return a.Offset == b.Offset && reflect.DeepEqual(a.BPMs, b.BPMs) && reflect.DeepEqual(a.Stops, b.Stops)
+}
+
+// beatChanges converts #BPM, #BPM2 and #BPM3 to BPM changes. #BUNKI and #BUNKI2 are the seconds
+// after #STARTTIME at which #BPM2 and #BPM3 start, as StepMania reads them.
+func (t ksfTiming) beatChanges() []BeatChange {
+	changes := []BeatChange{{Beat: 0, Value: t.bpms[0]}}
+	beat, seconds := 0.0, 0.0
+	for i, bunki := range t.bunkis {
+		if bunki <= seconds || t.bpms[i+1] <= 0 {
+			break
+		}
+		beat += (bunki - seconds) * t.bpms[i] / 60
+		seconds = bunki
+		changes = append(changes, BeatChange{Beat: beat, Value: t.bpms[i+1]})
+	}
+	return changes
+}
+
+// ksfTickCount parses a #TICKCOUNT, the number of #STEP rows per beat.
+func ksfTickCount(value string) int {
+	ticks := int(dwiFloat(value))
+	if ticks <= 0 {
+		panic(fmt.Sprintf("invalid #TICKCOUNT %q", value))
+	}
+	return ticks
+}
+
+// ksfRows decodes #STEP rows into Rows, returning them with the length of the chart in 192nds
+// and its number of columns, 10 when any row has notes for the second pad.
+//
+// "1" is a tap and a run of "4" is a hold from its first to its last row; a lone "4" is a tap.
+// A row of "2" ends the chart. Lines starting with "|" change the timing: |T4| sets the tick
+// count, |B150| the BPM and |D500| adds a stop in milliseconds. BPM changes and stops are
+// added to header.
+func ksfRows(steps string, tickCount int, columns int, header *Header) ([]Row, int, int) {
+	lines := []Row{}
+	beat := 0.0
+scan:
+	for _, line := range strings.Split(steps, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "//"):
+		case strings.HasPrefix(line, "22222"):
+			break scan
+		case strings.HasPrefix(line, "|") && len(line) > 2:
+			value := strings.Trim(line[2:], "|")
+			switch line[1] {
+			case 'T':
+				tickCount = ksfTickCount(value)
+			case 'B':
+				header.BPMs = append(header.BPMs, BeatChange{Beat: beat, Value: dwiFloat(value)})
+			case 'D':
+				header.Stops = append(header.Stops, BeatChange{Beat: beat, Value: dwiFloat(value) / 1000})
+			default:
+				panic(fmt.Sprintf("unknown KSF directive %q", line))
+			}
+		case len(line) < 5:
+			panic(fmt.Sprintf("KSF row %q has fewer than 5 columns", line))
+		default:
+			row := Row{Index: int32(math.Round(beat * RowsPerMeasure / 4))}
+			for column := 0; column < len(line) && column < 10; column++ {
+				switch line[column] {
+				case '0':
+				case '1':
+					row.SetKind(column, NoteTap)
+				case '4':
+					row.SetKind(column, NoteHoldHead)
+				default:
+					panic(fmt.Sprintf("unknown KSF note %q in row %q", line[column], line))
+				}
+				if column >= 5 && row.Kind(column) != NoteEmpty {
+					columns = 10
+				}
+			}
+			lines = append(lines, row)
+			beat += 1 / float64(tickCount)
+		}
+	}
+
+	// Turn runs of "4" into a hold head on the first row and a tail on the last.
+	for column := 0; column < columns; column++ {
+		for start := 0; start < len(lines); start++ {
+			if lines[start].Kind(column) != NoteHoldHead {
+				continue
+			}
+			last := start
+			for last+1 < len(lines) && lines[last+1].Kind(column) == NoteHoldHead {
+				last++
+				lines[last].SetKind(column, NoteEmpty)
+			}
+			if last == start {
+				lines[start].SetKind(column, NoteTap)
+			} else {
+				lines[last].SetKind(column, NoteTail)
+			}
+			start = last
+		}
+	}
+
+	rows := []Row{}
+	for _, row := range lines {
+		if !row.IsEmpty() {
+			rows = append(rows, row)
+		}
+	}
+	return rows, int(math.Round(beat * RowsPerMeasure / 4)), columns
+}
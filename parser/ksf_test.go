@@ -0,0 +1,160 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testKSF = `#TITLE:KIU Artist - KIU Song;
+#BPM:120;
+#BPM2:240;
+#BUNKI:200;
+#STARTTIME:150;
+#TICKCOUNT:4;
+#DIFFICULTY:12;
+#STEP:
+1000000000000
+0000000000000
+0400000000000
+0400000000000
+0400000000000
+0000100000000
+|T2|
+0010000000000
+4000000000000
+2222222222222
+`
+
+const testKSFDouble = "#TITLE:Other;\n#BPM:120;\n#BPM2:240;\n#BUNKI:200;\n#STARTTIME:150;\n#TICKCOUNT:4;\n" +
+	"#STEP:\n1000000001000\n2222222222222\n"
+
+func testKSFFolder() fstest.MapFS {
+	return fstest.MapFS{
+		"pack/song/Crazy_1.ksf": {Data: []byte(testKSF)},
+		"pack/song/Double.ksf":  {Data: []byte(testKSFDouble)},
+		"pack/song/song.mp3":    {Data: []byte{}},
+	}
+}
+
+// chartRows returns the non-empty steps of a chart as strings of panel values.
+func chartRows(chart Chart) []string {
+	rows := []string{}
+	for _, measure := range chart.Notes {
+		for _, step := range measure.Steps {
+			row := strings.Join(step.Panels(), "")
+			if strings.Trim(row, "0") != "" {
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+func TestParseKSF(t *testing.T) {
+	sim, err := ParseKSF(testKSFFolder(), "pack/song")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := sim.Header
+	if header.Title != "KIU Song" || header.Artist != "KIU Artist" || header.Offset != -1.5 {
+		errorMsg := fmt.Sprintf("KSF header parsed incorrectly: %+v", header)
+		t.Error(errorMsg)
+	}
+	if bpms := []BeatChange{{0, 120}, {4, 240}}; !reflect.DeepEqual(header.BPMs, bpms) {
+		errorMsg := fmt.Sprintf("Expected BPMs %v, received: %v", bpms, header.BPMs)
+		t.Error(errorMsg)
+	}
+
+	var tests = []struct {
+		chartType     string
+		difficulty    string
+		meter         int
+		quantizations []int
+		rows          []string
+	}{
+		{"pump-single", "Hard", 12, []int{16}, []string{"10000", "02000", "03000", "00001", "00100", "10000"}},
+		{"pump-double", "Medium", 0, []int{4}, []string{"1000000001"}},
+	}
+	if len(sim.Charts) != len(tests) {
+		t.Fatal(fmt.Sprintf("Expected %d charts, received: %d", len(tests), len(sim.Charts)))
+	}
+	for i, test := range tests {
+		chart := sim.Charts[i]
+		quantizations := []int{}
+		for _, measure := range chart.Notes {
+			quantizations = append(quantizations, measure.Quantization)
+		}
+		rows := chartRows(chart)
+		if chart.Type != test.chartType || chart.Difficulty != test.difficulty || chart.Meter != test.meter ||
+			!reflect.DeepEqual(quantizations, test.quantizations) || !reflect.DeepEqual(rows, test.rows) {
+			errorMsg := fmt.Sprintf("Expected %s %s %d %v %v, received: %s %s %d %v %v", test.chartType, test.difficulty, test.meter, test.quantizations, test.rows,
+				chart.Type, chart.Difficulty, chart.Meter, quantizations, rows)
+			t.Error(errorMsg)
+		}
+	}
+	if notes := Summarize(sim.Charts[0], header).Notes; notes != 5 || sim.Charts[0].Analysis.Density.PeakNPS == 0 {
+		errorMsg := fmt.Sprintf("Expected 5 notes and a density, received: %d %+v", notes, sim.Charts[0].Analysis.Density)
+		t.Error(errorMsg)
+	}
+}
+
+func TestParseFSKSF(t *testing.T) {
+	sim, err := ParseFS(testKSFFolder(), "pack/song/Double.ksf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim.SongPack != "pack" || len(sim.Charts) != 2 {
+		t.Error("ParseFS did not parse the song folder of the .ksf file.")
+	}
+
+	data, err := MarshalProto(sim)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := UnmarshalProto(data)
+	if err != nil || !reflect.DeepEqual(decoded.Charts[1].Notes, sim.Charts[1].Notes) {
+		errorMsg := fmt.Sprintf("Expected pump notes to round trip through protobuf, received: %v (%v)", decoded.Charts[1].Notes, err)
+		t.Error(errorMsg)
+	}
+}
+
+func TestParseKSFErrors(t *testing.T) {
+	var tests = []string{
+		"#TICKCOUNT:0;\n#STEP:\n10000\n",
+		"#BPM:120;\n#STEP:\n10X00\n",
+		"#BPM:120;\n#STEP:\n100\n",
+		"#BPM:120;\n#STEP:\n|Q1|\n10000\n",
+	}
+
+	for _, test := range tests {
+		fsys := fstest.MapFS{"song/Easy_1.ksf": {Data: []byte(test)}}
+		if _, err := ParseKSF(fsys, "song"); err == nil || !strings.HasPrefix(err.Error(), "Parse Error") {
+			errorMsg := fmt.Sprintf("Expected a parse error for %q, received: %v", test, err)
+			t.Error(errorMsg)
+		}
+	}
+	// Every chart of the song folder must share the timing of Crazy_1.ksf.
+	var timings = []struct {
+		old string
+		new string
+	}{
+		{"#BPM:120;", "#BPM:150;"},
+		{"#STARTTIME:150;", "#STARTTIME:100;"},
+		{"#STEP:\n", "#STEP:\n|D500|\n"},
+	}
+	for _, timing := range timings {
+		fsys := testKSFFolder()
+		fsys["pack/song/Double.ksf"] = &fstest.MapFile{Data: []byte(strings.Replace(testKSFDouble, timing.old, timing.new, 1))}
+		if _, err := ParseKSF(fsys, "pack/song"); err == nil || !strings.Contains(err.Error(), "timing of Double.ksf") {
+			errorMsg := fmt.Sprintf("Expected a timing error for a Double.ksf with %q, received: %v", timing.new, err)
+			t.Error(errorMsg)
+		}
+	}
+	if _, err := ParseKSF(testKSFFolder(), "pack"); err == nil {
+		t.Error("Expected an error for a folder without .ksf files.")
+	}
+}
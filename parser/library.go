@@ -0,0 +1,193 @@
+package parser
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// simfileFormats lists the simfile extensions this parser reads, in the order StepMania prefers
+// them within a song folder. StepMania prefers .ssc over all of them, but it cannot be parsed yet.
+var simfileFormats = []string{".sm", ".dwi", ".ksf", ".ucs"}
+
+// Library is an index of a StepMania Songs folder.
+type Library struct {
+	Root   string      `json:"root"`
+	Packs  []Pack      `json:"packs"`
+	Errors []SongError `json:"-"`
+}
+
+// Pack is a folder of songs.
+type Pack struct {
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	Songs []Song `json:"songs"`
+}
+
+// Song is a song folder and its parsed simfile.
+type Song struct {
+	Name    string  `json:"name"`
+	Path    string  `json:"path"`
+	Simfile Simfile `json:"simfile"`
+}
+
+// SongError is an error reading or parsing the simfile of a song folder.
+type SongError struct {
+	Path string
+	Err  error
+}
+
+func (e SongError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Path, e.Err)
+}
+
+// ScanLibrary parses every song in a Songs folder laid out as <root>/<Pack>/<Song>/.
+//
+// Simfiles are parsed concurrently with ParseAll. Songs that fail to parse are left out of
+// their pack and collected in Library.Errors. Only an unreadable root folder or a cancelled
+// ctx is returned as an error.
+func ScanLibrary(ctx context.Context, root string, options BatchOptions) (Library, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return Library{Root: root, Packs: []Pack{}}, err
+	}
+	options.FSPath = filepath.ToSlash(abs)
+	return ScanLibraryFS(ctx, os.DirFS(root), root, options)
+}
+
+// ScanZip parses every song in a zip archive, treating its top-level folders as packs.
+func ScanZip(ctx context.Context, zipPath string, options BatchOptions) (Library, error) {
+	abs, err := filepath.Abs(zipPath)
+	if err != nil {
+		return Library{Root: zipPath, Packs: []Pack{}}, err
+	}
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return Library{Root: zipPath, Packs: []Pack{}}, err
+	}
+	defer archive.Close()
+	options.FSPath = filepath.ToSlash(abs)
+	return ScanLibraryFS(ctx, archive, zipPath, options)
+}
+
+// ScanLibraryFS parses every song in a file system laid out as <Pack>/<Song>/, like ScanLibrary.
+//
+// Paths in the Library are joined to root, which names where fsys is located. Cache keys are
+// joined to options.FSPath, or to root when it is empty; ScanLibrary and ScanZip set it to the
+// absolute path of root, so scans from different working directories share entries.
+func ScanLibraryFS(ctx context.Context, fsys fs.FS, root string, options BatchOptions) (Library, error) {
+	library := Library{Root: root, Packs: []Pack{}}
+	packDirs, err := subdirectories(fsys, ".")
+	if err != nil {
+		return library, err
+	}
+
+	// Find the simfile of every song folder.
+	type songRef struct {
+		pack int
+		name string
+	}
+	refs := []songRef{}
+	names := []string{}
+	for _, packName := range packDirs {
+		pack := Pack{Name: packName, Path: path.Join(root, packName), Songs: []Song{}}
+		songDirs, err := subdirectories(fsys, packName)
+		if err != nil {
+			library.Errors = append(library.Errors, SongError{Path: pack.Path, Err: err})
+			continue
+		}
+
+		for _, songName := range songDirs {
+			songDir := path.Join(packName, songName)
+			simfile, err := findSimfile(fsys, songDir)
+			if err != nil {
+				library.Errors = append(library.Errors, SongError{Path: path.Join(root, songDir), Err: err})
+				continue
+			}
+			if simfile == "" {
+				continue
+			}
+			refs = append(refs, songRef{pack: len(library.Packs), name: songName})
+			names = append(names, path.Join(songDir, simfile))
+		}
+		library.Packs = append(library.Packs, pack)
+	}
+
+	// Parse them and add the songs to their packs.
+	options.FS = fsys
+	if options.FSPath == "" {
+		options.FSPath = root
+	}
+	results, err := ParseAll(ctx, names, options)
+	if err != nil {
+		return library, err
+	}
+	for i, result := range results {
+		ref := refs[i]
+		songPath := path.Join(root, result.Path)
+		if result.Err != nil {
+			library.Errors = append(library.Errors, SongError{Path: path.Dir(songPath), Err: result.Err})
+			continue
+		}
+		pack := &library.Packs[ref.pack]
+		result.Simfile.SongPack = pack.Name
+		pack.Songs = append(pack.Songs, Song{Name: ref.name, Path: songPath, Simfile: result.Simfile})
+	}
+	return library, nil
+}
+
+// findSimfile returns the name of the simfile in a song folder, or "" if it has none.
+func findSimfile(fsys fs.FS, songDir string) (string, error) {
+	entries, err := fs.ReadDir(fsys, songDir)
+	if err != nil {
+		return "", err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return SimfileName(names), nil
+}
+
+// SimfileName picks the simfile StepMania would load from the file names of a song folder, or
+// returns "" if none can be parsed.
+//
+// Formats this parser cannot read, like .ssc, are skipped, since StepMania writes the same
+// charts to each format. Of several .ksf files the first is returned, since ParseFile reads
+// the whole folder.
+func SimfileName(names []string) string {
+	sorted := append([]string(nil), names...)
+	sort.Strings(sorted)
+
+	for _, format := range simfileFormats {
+		for _, name := range sorted {
+			if strings.ToLower(path.Ext(name)) == format {
+				return name
+			}
+		}
+	}
+	return ""
+}
+
+// subdirectories returns the sorted names of the folders in a directory.
+func subdirectories(fsys fs.FS, dir string) ([]string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
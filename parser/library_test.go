@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"archive/zip"
+	"context"
+	"path"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/spf13/afero"
+)
+
+func TestScanLibrary(t *testing.T) {
+	library, err := ScanLibrary(context.Background(), "../testdata", BatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(library.Packs) != 1 || library.Packs[0].Name != "sharpnelstreamz" {
+		t.Fatal("Pack not found in library.")
+	}
+
+	songs := library.Packs[0].Songs
+	if len(songs) != 2 || songs[0].Name != "200312023" || songs[1].Name != "bluearmy" {
+		t.Fatal("Songs not found in pack.")
+	}
+	if songs[1].Simfile.Header.Title != "Blue Army" || songs[1].Simfile.SongPack != "sharpnelstreamz" {
+		t.Error("Song simfile not parsed.")
+	}
+	if len(songs[1].Simfile.Charts) == 0 {
+		t.Error("Song charts not parsed.")
+	}
+	if len(library.Errors) != 0 {
+		t.Error("Library scan returned unexpected errors.")
+	}
+}
+
+func TestScanLibraryCollectsErrors(t *testing.T) {
+	var Fs = afero.NewOsFs()
+	root, _ := afero.TempDir(Fs, "", "_")
+	defer Fs.RemoveAll(root)
+
+	var files = map[string]string{
+		"pack/good/good.sm":     "#TITLE:Good;",
+		"pack/bad/bad.sm":       "#NOTES:dance-single:::x:;",
+		"pack/newer/newer.ssc":  "#TITLE:Newer;",
+		"pack/empty/readme.txt": "",
+	}
+	for name, data := range files {
+		Fs.MkdirAll(path.Dir(path.Join(root, name)), 0755)
+		afero.WriteFile(Fs, path.Join(root, name), []byte(data), 0644)
+	}
+
+	library, err := ScanLibrary(context.Background(), root, BatchOptions{Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(library.Packs[0].Songs) != 1 || library.Packs[0].Songs[0].Simfile.Header.Title != "Good" {
+		t.Error("Expected only the good song in the pack.")
+	}
+	if len(library.Errors) != 1 {
+		t.Fatal("Expected an error for the bad song only, since .ssc songs are skipped.")
+	}
+	if library.Errors[0].Path != path.Join(root, "pack", "bad") {
+		t.Error("Song error has the wrong path.")
+	}
+}
+
+func TestScanLibraryMissingRoot(t *testing.T) {
+	if _, err := ScanLibrary(context.Background(), "../testdata/missing", BatchOptions{}); err == nil {
+		t.Error("Expected an error for a missing root.")
+	}
+}
+
+func TestTableSimfileName(t *testing.T) {
+	var tests = []struct {
+		names  []string
+		result string
+	}{
+		{[]string{"song.sm", "song.ogg"}, "song.sm"},
+		{[]string{"song.dwi", "song.sm", "song.ssc"}, "song.sm"},
+		{[]string{"song.dwi", "song.ssc"}, "song.dwi"},
+		{[]string{"song.ssc"}, ""},
+		{[]string{"song.ksf", "song.sm"}, "song.sm"},
+		{[]string{"Hard_1.ksf", "Easy_1.ksf"}, "Easy_1.ksf"},
+		{[]string{"b.sm", "a.sm"}, "a.sm"},
+		{[]string{"song.ogg", "song.png"}, ""},
+	}
+
+	for _, test := range tests {
+		if output := SimfileName(test.names); output != test.result {
+			t.Errorf("Expected %s, received: %s", test.result, output)
+		}
+	}
+}
+
+func TestScanZip(t *testing.T) {
+	var Fs = afero.NewOsFs()
+	dir, _ := afero.TempDir(Fs, "", "_")
+	defer Fs.RemoveAll(dir)
+
+	zipPath := path.Join(dir, "pack.zip")
+	file, _ := Fs.Create(zipPath)
+	archive := zip.NewWriter(file)
+	data, _ := ReadSM("../testdata/sharpnelstreamz/bluearmy/bluearmy.sm")
+	w, _ := archive.Create("Sharpnel Streamz/bluearmy/bluearmy.sm")
+	w.Write(data)
+	archive.Close()
+	file.Close()
+
+	library, err := ScanZip(context.Background(), zipPath, BatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(library.Packs) != 1 || library.Packs[0].Name != "Sharpnel Streamz" {
+		t.Fatal("Top-level folder of the zip not used as the pack.")
+	}
+	song := library.Packs[0].Songs[0]
+	if song.Simfile.Header.Title != "Blue Army" || song.Simfile.SongPack != "Sharpnel Streamz" {
+		t.Error("Song in the zip not parsed.")
+	}
+	if song.Path != path.Join(zipPath, "Sharpnel Streamz/bluearmy/bluearmy.sm") {
+		t.Error("Song path not joined to the zip path.")
+	}
+
+	if _, err := ScanZip(context.Background(), path.Join(dir, "missing.zip"), BatchOptions{}); err == nil {
+		t.Error("Expected an error for a missing zip.")
+	}
+}
+
+func TestScanLibraryCacheKeys(t *testing.T) {
+	var Fs = afero.NewOsFs()
+	dir, _ := afero.TempDir(Fs, "", "_")
+	defer Fs.RemoveAll(dir)
+	cache, _ := NewCache(dir)
+
+	if _, err := ScanLibrary(context.Background(), "../testdata", BatchOptions{Cache: cache}); err != nil {
+		t.Fatal(err)
+	}
+	root, _ := filepath.Abs("../testdata")
+	if _, ok := cache.load(filepath.ToSlash(root) + "/sharpnelstreamz/bluearmy/bluearmy.sm"); !ok {
+		t.Error("Cache entry not keyed by the absolute path of the library.")
+	}
+}
+
+func TestScanLibraryFSWithCache(t *testing.T) {
+	fsys := fstest.MapFS{
+		"pack/song/song.sm": &fstest.MapFile{Data: []byte("#TITLE:Mapped;")},
+	}
+	var Fs = afero.NewOsFs()
+	dir, _ := afero.TempDir(Fs, "", "_")
+	defer Fs.RemoveAll(dir)
+	cache, _ := NewCache(dir)
+
+	library, err := ScanLibraryFS(context.Background(), fsys, "mapped", BatchOptions{Cache: cache})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if library.Packs[0].Songs[0].Simfile.Header.Title != "Mapped" {
+		t.Error("Song in the file system not parsed.")
+	}
+	if _, ok := cache.load("mapped/pack/song/song.sm"); !ok {
+		t.Error("Cache entry not keyed by the file system path.")
+	}
+}
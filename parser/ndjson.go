@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// ChartRecord is a chart with the fields of its song copied in, written by an NDJSONWriter
+// in chart mode so each line stands on its own.
+type ChartRecord struct {
+	SchemaVersion int          `json:"schema_version"`
+	Path          string       `json:"path,omitempty"`
+	SongPack      string       `json:"song_pack"`
+	Title         string       `json:"title"`
+	Subtitle      string       `json:"subtitle"`
+	Artist        string       `json:"artist"`
+	Genre         string       `json:"genre"`
+	Credit        string       `json:"credit"`
+	Offset        float64      `json:"offset"`
+	DisplayBPM    []float64    `json:"display_bpm"`
+	BPMs          []BeatChange `json:"bpms"`
+	Stops         []BeatChange `json:"stops"`
+	Delays        []BeatChange `json:"delays"`
+	ChartIndex    int          `json:"chart_index"`
+	Chart
+}
+
+// NDJSONWriter streams simfiles as newline-delimited JSON, one JSON document per line.
+//
+// It is safe for concurrent use. Lines are written to the underlying io.Writer as they are
+// encoded, so wrap it in a bufio.Writer for many small writes.
+type NDJSONWriter struct {
+	// Charts writes a ChartRecord for each chart instead of a line per Simfile. Charts of
+	// unsupported types, which are left empty by Parse, are skipped.
+	Charts bool
+
+	mu      sync.Mutex
+	encoder *json.Encoder
+}
+
+// NewNDJSONWriter returns an NDJSONWriter writing a Simfile per line to w.
+func NewNDJSONWriter(w io.Writer) *NDJSONWriter {
+	return &NDJSONWriter{encoder: json.NewEncoder(w)}
+}
+
+// Write writes a simfile read from source, which may be empty, as one line or a line per chart.
+func (n *NDJSONWriter) Write(sim Simfile, source string) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.Charts {
+		return n.encoder.Encode(sim)
+	}
+	for i, chart := range sim.Charts {
+		if chart.Type == "" {
+			continue
+		}
+		if err := n.encoder.Encode(chartRecord(sim, source, i, chart)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteBatch writes the simfiles of a ParseBatch channel as they arrive, returning the files
+// that failed to parse.
+//
+// A write error stops WriteBatch early; cancel the context of the batch to stop its workers.
+func (n *NDJSONWriter) WriteBatch(results <-chan BatchResult) ([]SongError, error) {
+	failed := []SongError{}
+	for result := range results {
+		if result.Err != nil {
+			failed = append(failed, SongError{Path: result.Path, Err: result.Err})
+			continue
+		}
+		if err := n.Write(result.Simfile, result.Path); err != nil {
+			return failed, err
+		}
+	}
+	return failed, nil
+}
+
+// WriteLibrary writes every song of a scanned Library.
+func (n *NDJSONWriter) WriteLibrary(library Library) error {
+	for _, pack := range library.Packs {
+		for _, song := range pack.Songs {
+			if err := n.Write(song.Simfile, song.Path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// chartRecord copies the song fields of a simfile into a chart.
+func chartRecord(sim Simfile, source string, index int, chart Chart) ChartRecord {
+	header := sim.Header
+	return ChartRecord{
+		SchemaVersion: sim.SchemaVersion,
+		Path:          source,
+		SongPack:      sim.SongPack,
+		Title:         header.Title,
+		Subtitle:      header.Subtitle,
+		Artist:        header.Artist,
+		Genre:         header.Genre,
+		Credit:        header.Credit,
+		Offset:        header.Offset,
+		DisplayBPM:    header.DisplayBPM,
+		BPMs:          header.BPMs,
+		Stops:         header.Stops,
+		Delays:        header.Delays,
+		ChartIndex:    index,
+		Chart:         chart,
+	}
+}
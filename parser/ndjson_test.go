@@ -0,0 +1,73 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestNDJSONWriter(t *testing.T) {
+	sim := Simfile{SongPack: "Pack", Header: Header{Title: "Song"}, Charts: []Chart{{Type: "dance-single"}, {}, {Type: "dance-single"}}}
+
+	var tests = []struct {
+		charts bool
+		lines  int
+	}{
+		{false, 2},
+		{true, 4},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		writer := NewNDJSONWriter(&out)
+		writer.Charts = test.charts
+		writer.Write(sim, "Pack/Song/song.sm")
+		writer.Write(sim, "Pack/Song/song.sm")
+
+		lines := 0
+		scanner := bufio.NewScanner(&out)
+		for scanner.Scan() {
+			lines++
+			if !json.Valid(scanner.Bytes()) {
+				errorMsg := fmt.Sprintf("Expected a JSON document per line, received: %s", scanner.Text())
+				t.Error(errorMsg)
+			}
+		}
+		if lines != test.lines {
+			errorMsg := fmt.Sprintf("Expected %d lines, received: %d", test.lines, lines)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestNDJSONChartRecord(t *testing.T) {
+	var out bytes.Buffer
+	writer := NewNDJSONWriter(&out)
+	writer.Charts = true
+	writer.Write(Simfile{SongPack: "Pack", Header: Header{Title: "Song"}, Charts: []Chart{{}, {Type: "dance-single", Difficulty: "Hard", Meter: 9}}}, "song.sm")
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	record := map[string]interface{}{}
+	json.Unmarshal(lines[0], &record)
+	if record["title"] != "Song" || record["song_pack"] != "Pack" || record["path"] != "song.sm" ||
+		record["difficulty"] != "Hard" || record["meter"] != 9.0 || record["chart_index"] != 1.0 {
+		errorMsg := fmt.Sprintf("Chart record missing fields: %s", lines[0])
+		t.Error(errorMsg)
+	}
+}
+
+func TestNDJSONWriteBatch(t *testing.T) {
+	var out bytes.Buffer
+	writer := NewNDJSONWriter(&out)
+	failed, err := writer.WriteBatch(ParseBatch(context.Background(), batchPaths, BatchOptions{Ordered: true}))
+	if err != nil || len(failed) != 1 {
+		errorMsg := fmt.Sprintf("Expected 1 failed file, received: %v (%v)", failed, err)
+		t.Error(errorMsg)
+	}
+	if lines := bytes.Count(out.Bytes(), []byte("\n")); lines != 2 {
+		errorMsg := fmt.Sprintf("Expected 2 lines, received: %d", lines)
+		t.Error(errorMsg)
+	}
+}
@@ -0,0 +1,241 @@
+package parser
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// DefaultTemplate names JSON files after the song title.
+const DefaultTemplate = "{title}"
+
+// maxNameLength is the longest file name written, leaving room for suffixes and extensions.
+const maxNameLength = 200
+
+// Collision decides what a JSONWriter does when two simfiles map to the same file.
+type Collision int
+
+const (
+	// CollisionSuffix numbers later files, as in "Title (2).json". Files left by an earlier
+	// run are replaced.
+	CollisionSuffix Collision = iota
+	// CollisionOverwrite replaces the earlier file.
+	CollisionOverwrite
+	// CollisionError returns an error instead of writing, including for files left by an
+	// earlier run.
+	CollisionError
+)
+
+// JSONWriter writes simfiles as JSON files named by a template.
+//
+// A JSONWriter remembers the files it has written to handle collisions, and is safe for
+// concurrent use.
+type JSONWriter struct {
+	// Dir is the output directory. Missing directories are created.
+	Dir string
+	// Template names each file, without the .json extension. It may contain "/" to write
+	// into subdirectories and these placeholders, whose values are sanitized:
+	//
+	//	{pack}    the pack name
+	//	{song}    the song folder name
+	//	{file}    the simfile name without its extension
+	//	{title}   the song title, or the simfile name when there is none
+	//	{artist}  the song artist
+	//	{hash}    the first 8 hex digits of the SHA1 of the written output, which changes
+	//	          with the parser and schema version, not only with the source file
+	//	{difficulty}  the difficulty of the first chart, for files holding one chart
+	Template string
+	// Marshal and Extension select another encoding than JSON and .json, such as MarshalProto
+	// and .pb.
+	Marshal   func(sim Simfile) ([]byte, error)
+	Extension string
+	// Mirror, when set, is an input root whose directory structure is kept in Dir: a
+	// simfile at <Mirror>/a/b/song.sm is written under <Dir>/a/b/.
+	Mirror string
+	// Collision is the collision policy.
+	Collision Collision
+
+	mu      sync.Mutex
+	written map[string]bool
+}
+
+// NewJSONWriter returns a JSONWriter writing files named after song titles into dir.
+func NewJSONWriter(dir string) *JSONWriter {
+	return &JSONWriter{Dir: dir, Template: DefaultTemplate}
+}
+
+// Write serializes a simfile read from source and writes it atomically, returning the path
+// of the file. source may be empty when the simfile was not read from a file.
+func (w *JSONWriter) Write(sim Simfile, source string) (string, error) {
+	marshal := w.Marshal
+	if marshal == nil {
+		marshal = func(sim Simfile) ([]byte, error) { return json.Marshal(sim) }
+	}
+	data, err := marshal(sim)
+	if err != nil {
+		return "", err
+	}
+	name, err := w.outputPath(sim, source, data)
+	if err != nil {
+		return "", err
+	}
+
+	w.mu.Lock()
+	name, err = w.claim(name)
+	w.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(name, data, 0644); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// outputPath fills in the template, without collision handling.
+func (w *JSONWriter) outputPath(sim Simfile, source string, data []byte) (string, error) {
+	template := w.Template
+	if template == "" {
+		template = DefaultTemplate
+	}
+	sum := sha1.Sum(data)
+	file := strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+	if source == "" {
+		file = ""
+	}
+	title := sim.Header.Title
+	if strings.TrimSpace(title) == "" {
+		title = file
+	}
+	difficulty := ""
+	if len(sim.Charts) > 0 {
+		difficulty = sim.Charts[0].Difficulty
+	}
+	song := ""
+	if source != "" {
+		song = filepath.Base(filepath.Dir(source))
+	}
+
+	replacer := strings.NewReplacer(
+		"{pack}", SanitizeFilename(sim.SongPack),
+		"{song}", SanitizeFilename(song),
+		"{file}", SanitizeFilename(file),
+		"{title}", SanitizeFilename(title),
+		"{artist}", SanitizeFilename(sim.Header.Artist),
+		"{hash}", hex.EncodeToString(sum[:4]),
+		"{difficulty}", SanitizeFilename(difficulty),
+	)
+	parts := strings.Split(template, "/")
+	for i, part := range parts {
+		parts[i] = SanitizeFilename(replacer.Replace(part))
+	}
+	name := filepath.Join(parts...)
+
+	dir := w.Dir
+	if w.Mirror != "" && source != "" {
+		rel, err := filepath.Rel(w.Mirror, filepath.Dir(source))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", fmt.Errorf("Output Error: %s is not inside %s", source, w.Mirror)
+		}
+		dir = filepath.Join(dir, rel)
+	}
+	return filepath.Join(dir, name), nil
+}
+
+// claim applies the collision policy to a file name, without its extension, and records the
+// file it returns. It must be called with w.mu held.
+func (w *JSONWriter) claim(name string) (string, error) {
+	if w.written == nil {
+		w.written = map[string]bool{}
+	}
+	extension := w.Extension
+	if extension == "" {
+		extension = ".json"
+	}
+	output := name + extension
+	switch w.Collision {
+	case CollisionError:
+		if w.written[output] {
+			return "", fmt.Errorf("Output Error: %s was already written", output)
+		}
+		if _, err := os.Stat(output); err == nil {
+			return "", fmt.Errorf("Output Error: %s already exists", output)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	case CollisionSuffix:
+		for n := 2; w.written[output]; n++ {
+			output = fmt.Sprintf("%s (%d)%s", name, n, extension)
+		}
+	}
+	w.written[output] = true
+	return output, nil
+}
+
+// windowsReserved lists the file names Windows does not allow, ignoring extensions.
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// SanitizeFilename makes a string safe to use as a file name on Linux, macOS and Windows.
+//
+// Raw => AC/DC: "Live"?
+// Parsed => AC_DC_ _Live__
+func SanitizeFilename(name string) string {
+	name = strings.ToValidUTF8(name, "_")
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(strings.TrimSpace(name), ". ")
+
+	for len(name) > maxNameLength {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" {
+		return "untitled"
+	}
+	if base, _, _ := strings.Cut(name, "."); windowsReserved[strings.ToUpper(base)] {
+		name = "_" + name
+	}
+	return name
+}
+
+// writeFileAtomic writes data to a temporary file next to name and renames it into place,
+// so readers never see a partial file.
+func writeFileAtomic(name string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
@@ -0,0 +1,156 @@
+package parser
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+)
+
+func TestTableSanitizeFilename(t *testing.T) {
+	var tests = []struct {
+		name      string
+		sanitized string
+	}{
+		{"Blue Army", "Blue Army"},
+		{`AC/DC: "Live"?`, `AC_DC_ _Live__`},
+		{"back\\slash|pipe<>*", "back_slash_pipe___"},
+		{"tab\there", "tab_here"},
+		{"  dots... ", "dots"},
+		{"", "untitled"},
+		{"...", "untitled"},
+		{"con", "_con"},
+		{"LPT1.txt", "_LPT1.txt"},
+		{"console", "console"},
+		{"bad\xffutf8", "bad_utf8"},
+		{strings.Repeat("é", 150), strings.Repeat("é", 100)},
+	}
+	for _, test := range tests {
+		if output := SanitizeFilename(test.name); output != test.sanitized {
+			errorMsg := fmt.Sprintf("Expected %q for %q, received: %q", test.sanitized, test.name, output)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestJSONWriterTemplate(t *testing.T) {
+	var Fs = afero.NewOsFs()
+	outputDir, _ := afero.TempDir(Fs, "", "output")
+	defer Fs.RemoveAll(outputDir)
+
+	sim := Simfile{SongPack: "Pack: One", Header: Header{Title: "A/B", Artist: "DJ"}, Charts: []Chart{{Difficulty: "Hard"}}}
+	var tests = []struct {
+		template string
+		source   string
+		output   string
+	}{
+		{"", "", "A_B.json"},
+		{"{pack}/{song}/{title}", "songs/Pack/Song Dir/a.sm", "Pack_ One/Song Dir/A_B.json"},
+		{"{artist} - {file}", "songs/Pack/Song/chart.sm", "DJ - chart.json"},
+		{"{pack}/../{title}", "", "Pack_ One/untitled/A_B.json"},
+		{"{title} {difficulty}", "", "A_B Hard.json"},
+	}
+	for _, test := range tests {
+		writer := NewJSONWriter(outputDir)
+		writer.Template = test.template
+		output, err := writer.Write(sim, test.source)
+		expected := filepath.Join(outputDir, test.output)
+		if err != nil || output != expected {
+			errorMsg := fmt.Sprintf("Expected %s for %q, received: %s (%v)", expected, test.template, output, err)
+			t.Error(errorMsg)
+		}
+		if exists, _ := afero.Exists(Fs, expected); !exists {
+			errorMsg := fmt.Sprintf("Expected %s to be written.", expected)
+			t.Error(errorMsg)
+		}
+	}
+
+	writer := NewJSONWriter(outputDir)
+	writer.Template = "{hash}"
+	output, _ := writer.Write(sim, "")
+	if len(filepath.Base(output)) != len("01234567.json") {
+		errorMsg := fmt.Sprintf("Expected a hash file name, received: %s", output)
+		t.Error(errorMsg)
+	}
+}
+
+func TestJSONWriterCollisions(t *testing.T) {
+	var Fs = afero.NewOsFs()
+	outputDir, _ := afero.TempDir(Fs, "", "output")
+	defer Fs.RemoveAll(outputDir)
+	sim := Simfile{Header: Header{Title: "Same"}}
+
+	writer := NewJSONWriter(outputDir)
+	var names = []string{"Same.json", "Same (2).json", "Same (3).json"}
+	for _, name := range names {
+		output, err := writer.Write(sim, "")
+		if err != nil || filepath.Base(output) != name {
+			errorMsg := fmt.Sprintf("Expected %s, received: %s (%v)", name, output, err)
+			t.Error(errorMsg)
+		}
+	}
+
+	writer = NewJSONWriter(outputDir)
+	writer.Collision = CollisionOverwrite
+	writer.Write(sim, "")
+	if output, _ := writer.Write(sim, ""); filepath.Base(output) != "Same.json" {
+		t.Error("Overwrite collision did not replace the file.")
+	}
+
+	writer = NewJSONWriter(outputDir)
+	writer.Collision = CollisionError
+	if _, err := writer.Write(sim, ""); err == nil {
+		t.Error("Expected an error for an existing file.")
+	}
+
+	files, _ := afero.ReadDir(Fs, outputDir)
+	if len(files) != len(names) {
+		errorMsg := fmt.Sprintf("Expected %d files without temporary files, received: %d", len(names), len(files))
+		t.Error(errorMsg)
+	}
+}
+
+func TestJSONWriterMirror(t *testing.T) {
+	var Fs = afero.NewOsFs()
+	outputDir, _ := afero.TempDir(Fs, "", "output")
+	defer Fs.RemoveAll(outputDir)
+	sim := Simfile{Header: Header{Title: "Song"}}
+
+	writer := NewJSONWriter(outputDir)
+	writer.Mirror = "songs"
+	output, err := writer.Write(sim, "songs/Pack/Song/song.sm")
+	if expected := filepath.Join(outputDir, "Pack/Song/Song.json"); err != nil || output != expected {
+		errorMsg := fmt.Sprintf("Expected %s, received: %s (%v)", expected, output, err)
+		t.Error(errorMsg)
+	}
+	if _, err := writer.Write(sim, "other/Song/song.sm"); err == nil {
+		t.Error("Expected an error for a simfile outside the mirrored root.")
+	}
+}
+
+func TestWriteJSONError(t *testing.T) {
+	if err := WriteJSON(Simfile{}, "/dev/null/missing"); err == nil {
+		t.Error("Expected an error writing to an invalid directory.")
+	}
+}
+
+func TestJSONWriterMarshal(t *testing.T) {
+	var Fs = afero.NewOsFs()
+	outputDir, _ := afero.TempDir(Fs, "", "output")
+	defer Fs.RemoveAll(outputDir)
+
+	writer := NewJSONWriter(outputDir)
+	writer.Marshal, writer.Extension = MarshalProto, ".pb"
+	writer.Write(Simfile{Header: Header{Title: "Song"}}, "")
+	output, err := writer.Write(Simfile{Header: Header{Title: "Song"}}, "")
+	if err != nil || filepath.Base(output) != "Song (2).pb" {
+		errorMsg := fmt.Sprintf("Expected Song (2).pb, received: %s (%v)", output, err)
+		t.Error(errorMsg)
+	}
+	data, _ := afero.ReadFile(Fs, output)
+	if sim, err := UnmarshalProto(data); err != nil || sim.Header.Title != "Song" {
+		t.Error("Expected a protobuf file.")
+	}
+}
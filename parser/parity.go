@@ -0,0 +1,401 @@
+package parser
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Parity summarizes the foot assignment chosen for a chart.
+type Parity struct {
+	Crossovers   int     `json:"crossovers"`
+	Footswitches int     `json:"footswitches"`
+	Doublesteps  int     `json:"doublesteps"`
+	Jacks        int     `json:"jacks"`
+	Brackets     int     `json:"brackets"`
+	Cost         float64 `json:"cost"`
+}
+
+// Parity cost weights. Jacks and doublesteps are divided by the seconds since the previous row,
+// so they get more expensive as the chart gets faster.
+const (
+	jackWeight       = 0.5
+	doublestepWeight = 3.0
+	footswitchWeight = 4.0
+	crossoverWeight  = 5.0
+	facingWeight     = 5.0
+	bracketWeight    = 2.0
+	movementWeight   = 0.05
+	holdWeight       = 100.0
+)
+
+// Events counted while solving parity.
+const (
+	eventCrossover = 1 << iota
+	eventFootswitch
+	eventDoublestep
+	eventJack
+	eventBracket
+)
+
+// feetState is the position of both feet, as bitmasks of the panels under each foot.
+type feetState struct {
+	left  uint8
+	right uint8
+	held  uint8 // bit 0 is the left foot, bit 1 is the right foot
+	last  uint8 // feet that hit the previous row, same bits as held
+}
+
+// parityStates is the number of distinct feetStates, the range of feetState.index.
+const parityStates = 1 << 12
+
+// index packs a feetState into an integer below parityStates.
+func (s feetState) index() int {
+	return int(s.left) | int(s.right)<<4 | int(s.held)<<8 | int(s.last)<<10
+}
+
+// parityEntry is the cheapest way found to reach a feetState.
+type parityEntry struct {
+	cost   float64
+	prev   feetState
+	from   int // index of the previous row's parityNode
+	left   uint8
+	right  uint8
+	events uint8
+}
+
+// parityNode is a feetState reached at a row of the chart.
+type parityNode struct {
+	state feetState
+	parityEntry
+}
+
+// sidePanels is the bitmask of the Left and Right panels.
+const sidePanels = 1<<panelLeft | 1<<panelRight
+
+// panelX is the horizontal position of each panel in L, D, U, R order.
+var panelX = [4]float64{0, 1, 1, 2}
+
+// panelY is the vertical position of each panel in L, D, U, R order.
+var panelY = [4]float64{1, 0, 2, 1}
+
+// SolveParity assigns a foot to every note of a dance-single chart and returns the summary counts.
+//
+// The assignment is stored in Step.Feet as one character per panel in L, D, U, R order:
+// "L" for the left foot, "R" for the right foot and "-" for no note.
+func SolveParity(notes []Measure, timing Timing) Parity {
+	steps := []*Step{}
+	rows := []uint8{}
+	heads := []uint8{}
+	tails := []uint8{}
+	var pending uint8
+	for m := range notes {
+		for s := range notes[m].Steps {
+			step := &notes[m].Steps[s]
+			var row, head uint8
+			for panel, value := range step.Panels() {
+				bit := uint8(1) << uint(panel)
+				switch {
+				case value == "3":
+					pending |= bit
+				case isNote(value):
+					row |= bit
+					if value == "2" || value == "4" {
+						head |= bit
+					}
+				}
+			}
+			if row == 0 {
+				continue
+			}
+			steps = append(steps, step)
+			rows = append(rows, row)
+			heads = append(heads, head)
+			tails = append(tails, pending)
+			pending = 0
+		}
+	}
+	if len(rows) == 0 {
+		return Parity{}
+	}
+
+	// The nodes of every row are kept in one slice, and slots finds the node of a state in
+	// the row being solved.
+	nodes := make([]parityNode, 1, 8*len(rows)+1)
+	nodes[0].state = feetState{left: 1 << panelLeft, right: 1 << panelRight}
+	slots := make([]int32, parityStates)
+	moves := make([][3]uint8, 0, 16)
+	penalized := make([][3]uint8, 0, 16)
+	lo, hi := 0, 1
+	prevSeconds := math.Inf(-1)
+	for i, row := range rows {
+		seconds := timing.Seconds(steps[i].Beat)
+		dt := math.Max(seconds-prevSeconds, 0.05)
+		prevSeconds = seconds
+
+		// Equal costs keep the move from the lesser previous state, so the feet do not depend
+		// on the order states are reached in.
+		for n := lo; n < hi; n++ {
+			state, total := nodes[n].state, nodes[n].cost
+			released := releaseHolds(state, tails[i])
+			for _, move := range footMoves(released, row, moves[:0], penalized[:0]) {
+				to, cost, events := moveCost(released, move[0], move[1], heads[i], dt)
+				if move[2] == 1 {
+					cost += holdWeight
+				}
+				cost += total
+				entry := parityEntry{cost: cost, prev: state, from: n, left: move[0], right: move[1], events: events}
+				slot := slots[to.index()]
+				if slot == 0 {
+					nodes = append(nodes, parityNode{state: to, parityEntry: entry})
+					slots[to.index()] = int32(len(nodes))
+				} else if best := &nodes[slot-1]; cost < best.cost || (cost == best.cost && stateLess(state, best.prev)) {
+					best.parityEntry = entry
+				}
+			}
+		}
+		if len(nodes) == hi {
+			return Parity{}
+		}
+		for n := hi; n < len(nodes); n++ {
+			slots[nodes[n].state.index()] = 0
+		}
+		lo, hi = hi, len(nodes)
+	}
+
+	last := lo
+	for n := lo + 1; n < hi; n++ {
+		if nodes[n].cost < nodes[last].cost || (nodes[n].cost == nodes[last].cost && stateLess(nodes[n].state, nodes[last].state)) {
+			last = n
+		}
+	}
+	parity := Parity{Cost: nodes[last].cost}
+	for i, n := len(rows)-1, last; i >= 0; i, n = i-1, nodes[n].from {
+		entry := nodes[n].parityEntry
+		steps[i].Feet = feetStrings[entry.left][entry.right]
+		parity.Crossovers += countEvent(entry.events, eventCrossover)
+		parity.Footswitches += countEvent(entry.events, eventFootswitch)
+		parity.Doublesteps += countEvent(entry.events, eventDoublestep)
+		parity.Jacks += countEvent(entry.events, eventJack)
+		parity.Brackets += countEvent(entry.events, eventBracket)
+	}
+	return parity
+}
+
+// countEvent returns 1 if the event is set.
+func countEvent(events uint8, event uint8) int {
+	if events&event != 0 {
+		return 1
+	}
+	return 0
+}
+
+// stateLess orders states so ties between equal costs are broken the same way every run.
+func stateLess(a feetState, b feetState) bool {
+	if a.left != b.left {
+		return a.left < b.left
+	}
+	if a.right != b.right {
+		return a.right < b.right
+	}
+	if a.held != b.held {
+		return a.held < b.held
+	}
+	return a.last < b.last
+}
+
+// releaseHolds lifts the hold on a foot whose held panel ended.
+func releaseHolds(state feetState, tails uint8) feetState {
+	if state.held&1 != 0 && state.left&tails != 0 {
+		state.held &^= 1
+	}
+	if state.held&2 != 0 && state.right&tails != 0 {
+		state.held &^= 2
+	}
+	return state
+}
+
+// footMoves lists the ways to split a row between the feet as [left, right, penalty] triples.
+//
+// Each foot hits at most two panels, and only a side arrow with Down or Up (a bracket).
+// If no split is possible without moving a foot that is holding, those splits are returned
+// with a penalty instead. The moves are appended to the two buffers.
+func footMoves(state feetState, row uint8, moves [][3]uint8, penalized [][3]uint8) [][3]uint8 {
+	for left := row; ; left = (left - 1) & row {
+		right := row &^ left
+		if footCanHit(left) && footCanHit(right) {
+			if (left != 0 && state.held&1 != 0) || (right != 0 && state.held&2 != 0) {
+				penalized = append(penalized, [3]uint8{left, right, 1})
+			} else {
+				moves = append(moves, [3]uint8{left, right, 0})
+			}
+		}
+		if left == 0 {
+			break
+		}
+	}
+	if len(moves) == 0 {
+		return penalized
+	}
+	return moves
+}
+
+// footCanHit reports whether one foot can hit all panels in a mask.
+func footCanHit(mask uint8) bool {
+	switch countPanels(mask) {
+	case 0, 1:
+		return true
+	case 2:
+		// One side arrow with Down or Up.
+		return countPanels(mask&sidePanels) == 1
+	}
+	return false
+}
+
+// moveCost returns the new state after the feet hit their panels, with its cost and events.
+func moveCost(state feetState, left uint8, right uint8, heads uint8, dt float64) (feetState, float64, uint8) {
+	cost := 0.0
+	var events uint8
+	to := state
+	to.last = 0
+
+	feet := [2]struct {
+		bit   uint8
+		hit   uint8
+		from  uint8
+		other uint8
+	}{
+		{1, left, state.left, state.right},
+		{2, right, state.right, state.left},
+	}
+	for _, foot := range feet {
+		if foot.hit == 0 {
+			continue
+		}
+		to.last |= foot.bit
+		if foot.hit&heads != 0 {
+			to.held |= foot.bit
+		} else {
+			to.held &^= foot.bit
+		}
+
+		switch {
+		case foot.hit == foot.from && state.last&foot.bit != 0:
+			cost += jackWeight / dt
+			events |= eventJack
+		case foot.hit != foot.from && state.last == foot.bit:
+			cost += doublestepWeight / dt
+			events |= eventDoublestep
+		}
+		if foot.hit&foot.other != 0 {
+			cost += footswitchWeight
+			events |= eventFootswitch
+		}
+		if countPanels(foot.hit) == 2 {
+			cost += bracketWeight
+			events |= eventBracket
+		}
+		cost += movementWeight * panelDistances[foot.from][foot.hit]
+	}
+
+	if left != 0 {
+		to.left = left
+		to.right &^= left
+	}
+	if right != 0 {
+		to.right = right
+		to.left &^= right
+	}
+
+	// Staying crossed costs on every row, but only counts as one crossover.
+	if crossed(to.left, to.right) {
+		cost += crossoverWeight
+		if !crossed(state.left, state.right) {
+			events |= eventCrossover
+		}
+		if to.left&(1<<panelRight) != 0 && to.right&(1<<panelLeft) != 0 {
+			cost += facingWeight
+		}
+	}
+	return to, cost, events
+}
+
+// crossed reports whether a foot is on the opposite side of the pad.
+func crossed(left uint8, right uint8) bool {
+	return left&(1<<panelRight) != 0 || right&(1<<panelLeft) != 0 || footX(left, 0) > footX(right, 2)
+}
+
+// footX returns the horizontal position of a foot, or a default when it is off the pad.
+func footX(mask uint8, empty float64) float64 {
+	if mask == 0 {
+		return empty
+	}
+	x := 0.0
+	for m := mask; m != 0; m &= m - 1 {
+		x += panelX[bits.TrailingZeros8(m)]
+	}
+	return x / float64(countPanels(mask))
+}
+
+// panelDistances holds panelDistance for every pair of panel bitmasks.
+var panelDistances = func() (distances [16][16]float64) {
+	for from := range distances {
+		for to := range distances[from] {
+			distances[from][to] = panelDistance(uint8(from), uint8(to))
+		}
+	}
+	return distances
+}()
+
+// panelDistance returns how far a foot moves between two sets of panels.
+func panelDistance(from uint8, to uint8) float64 {
+	if from == 0 {
+		return 0
+	}
+	fromX, fromY, toX, toY := 0.0, 0.0, 0.0, 0.0
+	fromCount, toCount := float64(countPanels(from)), float64(countPanels(to))
+	for m := from; m != 0; m &= m - 1 {
+		fromX += panelX[bits.TrailingZeros8(m)] / fromCount
+		fromY += panelY[bits.TrailingZeros8(m)] / fromCount
+	}
+	for m := to; m != 0; m &= m - 1 {
+		toX += panelX[bits.TrailingZeros8(m)] / toCount
+		toY += panelY[bits.TrailingZeros8(m)] / toCount
+	}
+	return math.Hypot(toX-fromX, toY-fromY)
+}
+
+// countPanels returns the number of panels in a bitmask.
+func countPanels(mask uint8) int {
+	return bits.OnesCount8(mask)
+}
+
+// feetStrings holds feetString for every pair of panel bitmasks, so solving a chart does not
+// allocate a string per row.
+var feetStrings = func() (feet [16][16]string) {
+	for left := range feet {
+		for right := range feet[left] {
+			feet[left][right] = feetString(uint8(left), uint8(right))
+		}
+	}
+	return feet
+}()
+
+// feetString formats a foot assignment for Step.Feet.
+//
+// Raw => left: L, right: U
+// Parsed => "L-R-"
+func feetString(left uint8, right uint8) string {
+	var feet [4]byte
+	for panel := range feet {
+		bit := uint8(1) << uint(panel)
+		switch {
+		case left&bit != 0:
+			feet[panel] = 'L'
+		case right&bit != 0:
+			feet[panel] = 'R'
+		default:
+			feet[panel] = '-'
+		}
+	}
+	return string(feet[:])
+}
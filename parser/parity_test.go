@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTableSolveParity(t *testing.T) {
+	var tests = []struct {
+		notes string
+		feet  []string
+	}{
+		{"1000000110000001", []string{"L---", "---R", "L---", "---R"}},
+		{"1000001000010000", []string{"L---", "--R-", "---L"}},
+		{"1001", []string{"L--R"}},
+		{"1100", []string{"LR--"}},
+		{"0002110000000003", []string{"---R", "LL--"}},
+		{"2000010000103000", []string{"L---", "-R--", "--R-"}},
+	}
+
+	// Quarter notes at 600 BPM are as fast as 16ths at 150 BPM.
+	timing := NewTiming(Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 600}}})
+	for _, test := range tests {
+		notes := noteData(test.notes)
+		SolveParity(notes, timing)
+		feet := []string{}
+		for _, step := range notes[0].Steps {
+			if step.Feet != "" {
+				feet = append(feet, step.Feet)
+			}
+		}
+		if fmt.Sprint(feet) != fmt.Sprint(test.feet) {
+			errorMsg := fmt.Sprintf("Expected feet %v for %s, received: %v", test.feet, test.notes, feet)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestSolveParityCounts(t *testing.T) {
+	timing := NewTiming(Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 600}}})
+	var tests = []struct {
+		notes  string
+		parity Parity
+	}{
+		{"1000001000010000", Parity{Crossovers: 1}},
+		{"0002110000000003", Parity{Brackets: 1}},
+		{"2000010000103000", Parity{Doublesteps: 1}},
+		{"1000100010001000", Parity{Jacks: 3}},
+		{"1000010001000010", Parity{Footswitches: 1}},
+	}
+
+	for _, test := range tests {
+		parity := SolveParity(noteData(test.notes), timing)
+		parity.Cost = 0
+		if parity != test.parity {
+			errorMsg := fmt.Sprintf("Expected %+v for %s, received: %+v", test.parity, test.notes, parity)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestSolveParityTieBreak(t *testing.T) {
+	// Down after the jump costs the same with either foot; the lesser previous state wins.
+	notes := noteData("1001010010010000")
+	SolveParity(notes, NewTiming(Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 600}}}))
+	feet := []string{}
+	for _, step := range notes[0].Steps {
+		if step.Feet != "" {
+			feet = append(feet, step.Feet)
+		}
+	}
+	if expected := []string{"L--R", "-R--", "L--R"}; fmt.Sprint(feet) != fmt.Sprint(expected) {
+		errorMsg := fmt.Sprintf("Expected feet %v, received: %v", expected, feet)
+		t.Error(errorMsg)
+	}
+}
+
+func TestSolveParityEmpty(t *testing.T) {
+	parity := SolveParity(noteData("0000"), NewTiming(Header{}))
+	if parity != (Parity{}) {
+		t.Error("Chart without notes should have an empty Parity.")
+	}
+}
+
+func TestFeetString(t *testing.T) {
+	if output := feetString(1<<panelLeft, 1<<panelUp); output != "L-R-" {
+		errorMsg := fmt.Sprintf("Expected L-R-, received: %s", output)
+		t.Error(errorMsg)
+	}
+}
+
+func TestSolveParityAllocs(t *testing.T) {
+	sim, _ := ParseFile("../testdata/sharpnelstreamz/bluearmy/bluearmy.sm")
+	chart := sim.Charts[0]
+	timing := NewTiming(sim.Header)
+
+	// The solver only allocates and grows its own slices, never per row, state or move.
+	if allocs := testing.AllocsPerRun(1, func() { SolveParity(chart.Notes, timing) }); allocs > 64 {
+		errorMsg := fmt.Sprintf("Expected at most 64 allocations for %d measures, received: %.0f", len(chart.Notes), allocs)
+		t.Error(errorMsg)
+	}
+}
+
+func BenchmarkSolveParity(b *testing.B) {
+	sim, _ := ParseFile("../testdata/sharpnelstreamz/bluearmy/bluearmy.sm")
+	chart := sim.Charts[0]
+	timing := NewTiming(sim.Header)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		SolveParity(chart.Notes, timing)
+	}
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"io"
+	"sync"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetBatch is the number of rows buffered before they are written to a Parquet file.
+const parquetBatch = 4096
+
+// ParquetWriter writes the charts of simfiles as a Parquet file with the ChartRow columns,
+// and optionally their notes as a second file with the NoteRow columns.
+//
+// Charts are numbered from 1 in the order they are written, as by CSVWriter. A ParquetWriter
+// is safe for concurrent use; Close must be called to write the file footers.
+type ParquetWriter struct {
+	mu     sync.Mutex
+	charts *parquet.GenericWriter[ChartRow]
+	notes  *parquet.GenericWriter[NoteRow]
+	rows   []NoteRow
+	nextID int64
+}
+
+// NewParquetWriter returns a ParquetWriter writing the charts table to charts and the notes
+// table to notes, which may be nil to skip it.
+func NewParquetWriter(charts io.Writer, notes io.Writer) *ParquetWriter {
+	w := &ParquetWriter{charts: parquet.NewGenericWriter[ChartRow](charts), nextID: 1}
+	if notes != nil {
+		w.notes = parquet.NewGenericWriter[NoteRow](notes)
+	}
+	return w
+}
+
+// Write writes the charts of a simfile read from source, which may be empty.
+func (w *ParquetWriter) Write(sim Simfile, source string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for i, chart := range sim.Charts {
+		if chart.Type == "" {
+			continue
+		}
+		id := w.nextID
+		w.nextID++
+		if _, err := w.charts.Write([]ChartRow{newChartRow(id, sim, source, i, chart)}); err != nil {
+			return err
+		}
+		if w.notes == nil {
+			continue
+		}
+
+		for note := range noteTableRows(id, chart, sim.Header) {
+			w.rows = append(w.rows, note)
+			if len(w.rows) == parquetBatch {
+				if err := w.flushNotes(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// flushNotes writes the buffered note rows. It must be called with w.mu held.
+func (w *ParquetWriter) flushNotes() error {
+	_, err := w.notes.Write(w.rows)
+	w.rows = w.rows[:0]
+	return err
+}
+
+// Close writes the remaining rows and the file footers. It does not close the underlying writers.
+func (w *ParquetWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := w.charts.Close(); err != nil {
+		return err
+	}
+	if w.notes == nil {
+		return nil
+	}
+	if err := w.flushNotes(); err != nil {
+		return err
+	}
+	return w.notes.Close()
+}
@@ -0,0 +1,68 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+func TestParquetWriter(t *testing.T) {
+	sim := Simfile{
+		SongPack: "Pack",
+		Header:   Header{Title: "Song", BPMs: []BeatChange{BeatChange{Beat: 0, Value: 120}}},
+		Charts:   []Chart{{}, {Type: "dance-single", Difficulty: "Hard", Meter: 9, Notes: noteData("1001,00M0")}},
+	}
+	sim.Charts[1].Analysis = Analyze(sim.Charts[1], sim.Header)
+
+	var charts, notes bytes.Buffer
+	writer := NewParquetWriter(&charts, &notes)
+	writer.Write(sim, "Pack/Song/song.sm")
+	writer.Write(sim, "Pack/Song/song.sm")
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	chartRows, err := parquet.Read[ChartRow](bytes.NewReader(charts.Bytes()), int64(charts.Len()))
+	if err != nil || len(chartRows) != 2 {
+		t.Fatal(fmt.Sprintf("Expected 2 charts, received: %d (%v)", len(chartRows), err))
+	}
+	expected := newChartRow(2, sim, "Pack/Song/song.sm", 1, sim.Charts[1])
+	if chartRows[1] != expected {
+		errorMsg := fmt.Sprintf("Expected %+v, received: %+v", expected, chartRows[1])
+		t.Error(errorMsg)
+	}
+
+	noteRows, err := parquet.Read[NoteRow](bytes.NewReader(notes.Bytes()), int64(notes.Len()))
+	if err != nil || len(noteRows) != 6 {
+		t.Fatal(fmt.Sprintf("Expected 6 notes, received: %d (%v)", len(noteRows), err))
+	}
+	mine := NoteRow{ChartID: 2, Measure: 1, Beat: 4, Seconds: 2, Snap: 4, Column: 2, Panel: "up", Note: "mine"}
+	if noteRows[5] != mine {
+		errorMsg := fmt.Sprintf("Expected %+v, received: %+v", mine, noteRows[5])
+		t.Error(errorMsg)
+	}
+}
+
+func TestParquetColumns(t *testing.T) {
+	var tests = []struct {
+		schema  *parquet.Schema
+		columns []string
+	}{
+		{parquet.SchemaOf(ChartRow{}), ChartColumns},
+		{parquet.SchemaOf(NoteRow{}), NoteColumns},
+	}
+	for _, test := range tests {
+		fields := test.schema.Fields()
+		if len(fields) != len(test.columns) {
+			t.Fatal(fmt.Sprintf("Expected %d columns, received: %d", len(test.columns), len(fields)))
+		}
+		for i, field := range fields {
+			if field.Name() != test.columns[i] {
+				errorMsg := fmt.Sprintf("Expected column %s, received: %s", test.columns[i], field.Name())
+				t.Error(errorMsg)
+			}
+		}
+	}
+}
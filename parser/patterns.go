@@ -0,0 +1,254 @@
+package parser
+
+// Pattern kinds recognized in dance-single charts.
+const (
+	PatternJack       = "jack"
+	PatternCandle     = "candle"
+	PatternCrossover  = "crossover"
+	PatternFootswitch = "footswitch"
+	PatternDrill      = "drill"
+	PatternTrill      = "trill"
+	PatternGallop     = "gallop"
+	PatternStaircase  = "staircase"
+	PatternBracket    = "bracket"
+	PatternSweep      = "sweep"
+)
+
+// Panel indexes in L, D, U, R order.
+const (
+	panelLeft = iota
+	panelDown
+	panelUp
+	panelRight
+)
+
+// Patterns contains the step patterns found in a chart.
+type Patterns struct {
+	Counts      map[string]int `json:"counts"`
+	Occurrences []Pattern      `json:"occurrences"`
+}
+
+// Pattern is a single occurrence of a step pattern, located at its first note.
+type Pattern struct {
+	Kind    string  `json:"kind"`
+	Measure int     `json:"measure_nbr"`
+	Beat    float64 `json:"beat"`
+}
+
+// noteRow is a step with at least one note, reduced to the panels that are hit.
+type noteRow struct {
+	measure int
+	beat    float64
+	panels  []int
+	feet    []int
+}
+
+// Feet assigned to notes by SolveParity.
+const (
+	footNone = iota
+	footLeft
+	footRight
+)
+
+// noteRows returns the rows of a chart that contain notes, in time order.
+func noteRows(chart Chart) []noteRow {
+	rows := []noteRow{}
+	for _, measure := range chart.Notes {
+		for _, step := range measure.Steps {
+			row := noteRow{measure: measure.MeasureNumber, beat: step.Beat}
+			for panel, value := range step.Panels() {
+				if isNote(value) {
+					row.panels = append(row.panels, panel)
+					row.feet = append(row.feet, stepFoot(step, panel))
+				}
+			}
+			if len(row.panels) > 0 {
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+// stepFoot returns the foot SolveParity assigned to a panel of a step.
+func stepFoot(step Step, panel int) int {
+	if len(step.Feet) != 4 {
+		return footNone
+	}
+	switch step.Feet[panel] {
+	case 'L':
+		return footLeft
+	case 'R':
+		return footRight
+	}
+	return footNone
+}
+
+// isSingle reports whether a row contains exactly one note.
+func (r noteRow) isSingle() bool {
+	return len(r.panels) == 1
+}
+
+// detectPatterns recognizes jacks, candles, crossovers, footswitches, drills, trills,
+// gallops, staircases, brackets and sweeps.
+//
+// Crossovers and candles use the feet assigned by SolveParity, so it must run first.
+func detectPatterns(chart Chart) Patterns {
+	patterns := Patterns{Counts: map[string]int{}, Occurrences: []Pattern{}}
+	add := func(kind string, row noteRow) {
+		patterns.Counts[kind]++
+		patterns.Occurrences = append(patterns.Occurrences, Pattern{Kind: kind, Measure: row.measure, Beat: row.beat})
+	}
+
+	rows := noteRows(chart)
+	for i, row := range rows {
+		if len(row.panels) == 2 && isBracket(row.panels) {
+			add(PatternBracket, row)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := rows[i-1]
+
+		if sharesPanel(prev, row) {
+			if isFootswitch(rows, i) {
+				add(PatternFootswitch, prev)
+			} else {
+				add(PatternJack, prev)
+			}
+		}
+		if row.isSingle() && row.feet[0] != footNone {
+			foot := row.feet[0]
+			if (foot == footLeft && row.panels[0] == panelRight) || (foot == footRight && row.panels[0] == panelLeft) {
+				add(PatternCrossover, row)
+			}
+			if i >= 2 && prev.isSingle() && rows[i-2].isSingle() && prev.feet[0] != foot && rows[i-2].feet[0] == foot &&
+				isCandle(rows[i-2].panels[0], row.panels[0]) {
+				add(PatternCandle, rows[i-2])
+			}
+		}
+		if isGallop(rows, i) {
+			add(PatternGallop, prev)
+		}
+		if i >= 3 && isStaircase(rows[i-3:i+1]) {
+			add(PatternStaircase, rows[i-3])
+			if i+3 < len(rows) && isSweep(rows[i-3:i+4]) {
+				add(PatternSweep, rows[i-3])
+			}
+		}
+	}
+
+	for _, run := range alternationRuns(rows) {
+		switch length := run[1] - run[0]; {
+		case length >= 8:
+			add(PatternDrill, rows[run[0]])
+		case length >= 4:
+			add(PatternTrill, rows[run[0]])
+		}
+	}
+	return patterns
+}
+
+// sharesPanel reports whether two rows hit any of the same panels.
+func sharesPanel(a noteRow, b noteRow) bool {
+	for _, p := range a.panels {
+		for _, q := range b.panels {
+			if p == q {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isFootswitch reports whether rows[i-1] and rows[i] are a repeated Down or Up single note
+// entered from another panel, which can be hit with alternating feet.
+func isFootswitch(rows []noteRow, i int) bool {
+	if i < 2 || !rows[i].isSingle() || !rows[i-1].isSingle() || !rows[i-2].isSingle() {
+		return false
+	}
+	panel := rows[i].panels[0]
+	return (panel == panelDown || panel == panelUp) && rows[i-2].panels[0] != panel
+}
+
+// isBracket reports whether a jump can be hit by one foot (a side arrow with Down or Up).
+func isBracket(panels []int) bool {
+	side, center := 0, 0
+	for _, p := range panels {
+		if p == panelLeft || p == panelRight {
+			side++
+		} else {
+			center++
+		}
+	}
+	return side == 1 && center == 1
+}
+
+// isCandle reports whether a foot moved between Down and Up.
+func isCandle(from int, to int) bool {
+	return (from == panelDown && to == panelUp) || (from == panelUp && to == panelDown)
+}
+
+// isGallop reports whether rows[i-1] and rows[i] are a quick pair between two longer gaps.
+func isGallop(rows []noteRow, i int) bool {
+	if i < 2 || i+1 >= len(rows) {
+		return false
+	}
+	gap := rows[i].beat - rows[i-1].beat
+	before := rows[i-1].beat - rows[i-2].beat
+	after := rows[i+1].beat - rows[i].beat
+	return gap <= 0.25 && before >= 2*gap && after >= 2*gap
+}
+
+// isStaircase reports whether four single notes hit every panel from one side to the other.
+//
+// LDUR, LUDR, RUDL and RDUL are staircases.
+func isStaircase(rows []noteRow) bool {
+	seen := [4]bool{}
+	for _, row := range rows {
+		if !row.isSingle() || seen[row.panels[0]] {
+			return false
+		}
+		seen[row.panels[0]] = true
+	}
+	first, last := rows[0].panels[0], rows[3].panels[0]
+	return (first == panelLeft && last == panelRight) || (first == panelRight && last == panelLeft)
+}
+
+// isSweep reports whether seven single notes are a staircase that turns back on itself.
+//
+// Raw => L D U R U D L
+func isSweep(rows []noteRow) bool {
+	for i := 4; i < 7; i++ {
+		if !rows[i].isSingle() || rows[i].panels[0] != rows[6-i].panels[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// alternationRuns returns [start, end) index pairs of at least four single notes alternating
+// between two panels.
+func alternationRuns(rows []noteRow) [][2]int {
+	runs := [][2]int{}
+	start := 0
+	for i := 1; i <= len(rows); i++ {
+		if i < len(rows) && alternates(rows, i) && (i-start < 2 || rows[i].panels[0] == rows[i-2].panels[0]) {
+			continue
+		}
+		if i-start >= 4 {
+			runs = append(runs, [2]int{start, i})
+		}
+		// The note before a broken run can start the next one.
+		start = i
+		if i < len(rows) && alternates(rows, i) {
+			start = i - 1
+		}
+	}
+	return runs
+}
+
+// alternates reports whether rows[i-1] and rows[i] are single notes on different panels.
+func alternates(rows []noteRow, i int) bool {
+	return rows[i].isSingle() && rows[i-1].isSingle() && rows[i].panels[0] != rows[i-1].panels[0]
+}
@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestTableDetectPatterns(t *testing.T) {
+	var tests = []struct {
+		notes string
+		kind  string
+		count int
+	}{
+		{"1000100000010001", PatternJack, 2},
+		{"1000010001000010", PatternFootswitch, 1},
+		{"0100100000100001", PatternCandle, 1},
+		{"1000001000010000", PatternCrossover, 1},
+		{"10000001100000011000000110000001", PatternTrill, 0},
+		{"1000000110000001", PatternTrill, 1},
+		{"10000001100000011000000110000001", PatternDrill, 1},
+		{"1000010000100001", PatternStaircase, 1},
+		{"1001110001100011", PatternBracket, 2},
+		{"1000010000100001001001001000", PatternSweep, 1},
+	}
+
+	timing := NewTiming(Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 150}}})
+	for _, test := range tests {
+		notes := noteData(test.notes)
+		SolveParity(notes, timing)
+		patterns := detectPatterns(Chart{Notes: notes})
+		if output := patterns.Counts[test.kind]; output != test.count {
+			errorMsg := fmt.Sprintf("Expected %d %s in %s, received: %d", test.count, test.kind, test.notes, output)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestDetectPatternsGallop(t *testing.T) {
+	// 8th, 16th, 8th => one gallop on the 16th pair.
+	chart := Chart{Notes: noteData("0000000010000000000110000000000100000000000000000000000000000000,1000")}
+	patterns := detectPatterns(chart)
+	if patterns.Counts[PatternGallop] != 1 {
+		errorMsg := fmt.Sprintf("Expected 1 gallop, received: %d", patterns.Counts[PatternGallop])
+		t.Error(errorMsg)
+	}
+	if patterns.Occurrences[0].Beat != 1.0 || patterns.Occurrences[0].Measure != 0 {
+		t.Error("Gallop located incorrectly.")
+	}
+}
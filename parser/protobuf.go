@@ -0,0 +1,532 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// MarshalProto encodes a simfile as a Simfile message of proto/simfile.proto.
+//
+// Empty lists and maps decode as nil with UnmarshalProto, as protobuf does not tell them apart.
+func MarshalProto(sim Simfile) ([]byte, error) {
+	e := protoEncoder{}
+	e.uint(1, sim.SchemaVersion)
+	e.string(2, sim.SongPack)
+	e.message(3, func(e *protoEncoder) { encodeProtoHeader(e, sim.Header) })
+	for _, chart := range sim.Charts {
+		e.message(4, func(e *protoEncoder) { encodeProtoChart(e, chart) })
+	}
+	return e, nil
+}
+
+// UnmarshalProto decodes a Simfile message of proto/simfile.proto. Unknown fields are skipped.
+func UnmarshalProto(data []byte) (Simfile, error) {
+	sim := Simfile{}
+	err := decodeProto(data, func(f protoField) error {
+		switch f.num {
+		case 1:
+			sim.SchemaVersion = f.int()
+		case 2:
+			sim.SongPack = f.string()
+		case 3:
+			return decodeProto(f.bytes, func(f protoField) error { return decodeProtoHeader(f, &sim.Header) })
+		case 4:
+			chart := Chart{}
+			if err := decodeProto(f.bytes, func(f protoField) error { return decodeProtoChart(f, &chart) }); err != nil {
+				return err
+			}
+			sim.Charts = append(sim.Charts, chart)
+		}
+		return nil
+	})
+	if err != nil {
+		return Simfile{}, fmt.Errorf("Proto Error: %v", err)
+	}
+	return sim, nil
+}
+
+// WriteProtoDelimited writes a simfile as a Simfile message preceded by its varint length, the
+// framing used to stream several messages, as by Java's writeDelimitedTo.
+func WriteProtoDelimited(w io.Writer, sim Simfile) error {
+	data, err := MarshalProto(sim)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(protowire.AppendVarint(nil, uint64(len(data)))); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// maxProtoMessage is the largest message ReadProtoDelimited accepts, far above any simfile.
+const maxProtoMessage = 1 << 28
+
+// ReadProtoDelimited reads a simfile written by WriteProtoDelimited. It returns io.EOF when
+// there are no more messages.
+//
+// The message is read as it arrives rather than allocated from its length, so a corrupt
+// length returns a Proto Error.
+func ReadProtoDelimited(r *bufio.Reader) (Simfile, error) {
+	size, err := readUvarint(r)
+	if err != nil {
+		return Simfile{}, err
+	}
+	if size > maxProtoMessage {
+		return Simfile{}, fmt.Errorf("Proto Error: message length %d is over %d bytes", size, maxProtoMessage)
+	}
+	var data bytes.Buffer
+	if n, err := data.ReadFrom(io.LimitReader(r, int64(size))); err != nil || n != int64(size) {
+		return Simfile{}, fmt.Errorf("Proto Error: %v", io.ErrUnexpectedEOF)
+	}
+	return UnmarshalProto(data.Bytes())
+}
+
+// readUvarint reads a message length, returning io.EOF only before its first byte.
+func readUvarint(r *bufio.Reader) (uint64, error) {
+	var size uint64
+	for shift := 0; shift < 64; shift += 7 {
+		b, err := r.ReadByte()
+		if err == io.EOF && shift > 0 {
+			err = fmt.Errorf("Proto Error: %v", io.ErrUnexpectedEOF)
+		}
+		if err != nil {
+			return 0, err
+		}
+		size |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return size, nil
+		}
+	}
+	return 0, errors.New("Proto Error: message length overflows")
+}
+
+func encodeProtoHeader(e *protoEncoder, h Header) {
+	for i, value := range []string{h.Title, h.Subtitle, h.Artist, h.TitleTranslit, h.SubtitleTranslit,
+		h.ArtistTranslit, h.Genre, h.Credit, h.Banner, h.Background, h.LyricsPath, h.CDTitle, h.Music} {
+		e.string(protowire.Number(i+1), value)
+	}
+	e.double(14, h.Offset)
+	e.double(15, h.SampleStart)
+	e.double(16, h.SampleLength)
+	e.string(17, h.Selectable)
+	e.doubles(18, h.DisplayBPM)
+	for i, changes := range [][]BeatChange{h.BPMs, h.Stops, h.BGChanges, h.KeySounds, h.Delays} {
+		for _, change := range changes {
+			e.message(protowire.Number(19+i), func(e *protoEncoder) {
+				e.double(1, change.Beat)
+				e.double(2, change.Value)
+			})
+		}
+	}
+}
+
+func decodeProtoHeader(f protoField, h *Header) error {
+	text := []*string{&h.Title, &h.Subtitle, &h.Artist, &h.TitleTranslit, &h.SubtitleTranslit,
+		&h.ArtistTranslit, &h.Genre, &h.Credit, &h.Banner, &h.Background, &h.LyricsPath, &h.CDTitle, &h.Music}
+	changes := []*[]BeatChange{&h.BPMs, &h.Stops, &h.BGChanges, &h.KeySounds, &h.Delays}
+	switch {
+	case f.num >= 1 && f.num <= 13:
+		*text[f.num-1] = f.string()
+	case f.num == 14:
+		h.Offset = f.double()
+	case f.num == 15:
+		h.SampleStart = f.double()
+	case f.num == 16:
+		h.SampleLength = f.double()
+	case f.num == 17:
+		h.Selectable = f.string()
+	case f.num == 18:
+		return f.appendDoubles(&h.DisplayBPM)
+	case f.num >= 19 && f.num <= 23:
+		change := BeatChange{}
+		err := decodeProto(f.bytes, func(f protoField) error {
+			switch f.num {
+			case 1:
+				change.Beat = f.double()
+			case 2:
+				change.Value = f.double()
+			}
+			return nil
+		})
+		*changes[f.num-19] = append(*changes[f.num-19], change)
+		return err
+	}
+	return nil
+}
+
+func encodeProtoChart(e *protoEncoder, c Chart) {
+	e.string(1, c.RawData)
+	e.string(2, c.Type)
+	e.string(3, c.Description)
+	e.string(4, c.Difficulty)
+	e.int(5, c.Meter)
+	e.message(6, func(e *protoEncoder) {
+		radar := c.GrooveRadar
+		for i, value := range []float64{radar.Stream, radar.Voltage, radar.Air, radar.Freeze, radar.Chaos} {
+			e.double(protowire.Number(i+1), value)
+		}
+	})
+	for _, measure := range c.Notes {
+		e.message(7, func(e *protoEncoder) {
+			e.int(1, measure.MeasureNumber)
+			e.int(2, measure.Quantization)
+			for _, step := range measure.Steps {
+				e.message(3, func(e *protoEncoder) {
+					e.double(1, step.Beat)
+					for i, value := range []string{step.L, step.D, step.U, step.R, step.Feet, step.Columns} {
+						e.string(protowire.Number(i+2), value)
+					}
+				})
+			}
+		})
+	}
+	e.message(8, func(e *protoEncoder) { encodeProtoAnalysis(e, c.Analysis) })
+}
+
+func decodeProtoChart(f protoField, c *Chart) error {
+	switch f.num {
+	case 1:
+		c.RawData = f.string()
+	case 2:
+		c.Type = f.string()
+	case 3:
+		c.Description = f.string()
+	case 4:
+		c.Difficulty = f.string()
+	case 5:
+		c.Meter = f.int()
+	case 6:
+		radar := []*float64{&c.GrooveRadar.Stream, &c.GrooveRadar.Voltage, &c.GrooveRadar.Air, &c.GrooveRadar.Freeze, &c.GrooveRadar.Chaos}
+		return decodeProto(f.bytes, func(f protoField) error {
+			if f.num >= 1 && f.num <= 5 {
+				*radar[f.num-1] = f.double()
+			}
+			return nil
+		})
+	case 7:
+		measure := Measure{}
+		err := decodeProto(f.bytes, func(f protoField) error {
+			switch f.num {
+			case 1:
+				measure.MeasureNumber = f.int()
+			case 2:
+				measure.Quantization = f.int()
+			case 3:
+				step := Step{}
+				values := []*string{&step.L, &step.D, &step.U, &step.R, &step.Feet, &step.Columns}
+				err := decodeProto(f.bytes, func(f protoField) error {
+					switch {
+					case f.num == 1:
+						step.Beat = f.double()
+					case f.num >= 2 && f.num <= 7:
+						*values[f.num-2] = f.string()
+					}
+					return nil
+				})
+				measure.Steps = append(measure.Steps, step)
+				return err
+			}
+			return nil
+		})
+		c.Notes = append(c.Notes, measure)
+		return err
+	case 8:
+		return decodeProto(f.bytes, func(f protoField) error { return decodeProtoAnalysis(f, &c.Analysis) })
+	}
+	return nil
+}
+
+func encodeProtoAnalysis(e *protoEncoder, a Analysis) {
+	e.message(1, func(e *protoEncoder) {
+		density := a.Density
+		e.doubles(1, density.PerMeasure)
+		e.ints(2, density.PerSecond)
+		e.double(3, density.PeakNPS)
+		e.int(4, density.PeakMeasure)
+		e.double(5, density.PeakSeconds)
+	})
+	e.message(2, func(e *protoEncoder) {
+		kinds := make([]string, 0, len(a.Patterns.Counts))
+		for kind := range a.Patterns.Counts {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		for _, kind := range kinds {
+			e.message(1, func(e *protoEncoder) {
+				e.string(1, kind)
+				e.int(2, a.Patterns.Counts[kind])
+			})
+		}
+		for _, pattern := range a.Patterns.Occurrences {
+			e.message(2, func(e *protoEncoder) {
+				e.string(1, pattern.Kind)
+				e.int(2, pattern.Measure)
+				e.double(3, pattern.Beat)
+			})
+		}
+	})
+	e.message(3, func(e *protoEncoder) {
+		parity := a.Parity
+		for i, value := range []int{parity.Crossovers, parity.Footswitches, parity.Doublesteps, parity.Jacks, parity.Brackets} {
+			e.int(protowire.Number(i+1), value)
+		}
+		e.double(6, parity.Cost)
+	})
+	e.message(4, func(e *protoEncoder) {
+		d := a.Difficulty
+		for i, value := range []float64{d.Overall, d.Stream, d.Jumpstream, d.Handstream, d.Stamina, d.Jackspeed, d.Chordjack, d.Technical} {
+			e.double(protowire.Number(i+1), value)
+		}
+	})
+}
+
+func decodeProtoAnalysis(f protoField, a *Analysis) error {
+	switch f.num {
+	case 1:
+		density := &a.Density
+		return decodeProto(f.bytes, func(f protoField) error {
+			switch f.num {
+			case 1:
+				return f.appendDoubles(&density.PerMeasure)
+			case 2:
+				return f.appendInts(&density.PerSecond)
+			case 3:
+				density.PeakNPS = f.double()
+			case 4:
+				density.PeakMeasure = f.int()
+			case 5:
+				density.PeakSeconds = f.double()
+			}
+			return nil
+		})
+	case 2:
+		patterns := &a.Patterns
+		return decodeProto(f.bytes, func(f protoField) error {
+			switch f.num {
+			case 1:
+				kind, count := "", 0
+				err := decodeProto(f.bytes, func(f protoField) error {
+					switch f.num {
+					case 1:
+						kind = f.string()
+					case 2:
+						count = f.int()
+					}
+					return nil
+				})
+				if patterns.Counts == nil {
+					patterns.Counts = map[string]int{}
+				}
+				patterns.Counts[kind] = count
+				return err
+			case 2:
+				pattern := Pattern{}
+				err := decodeProto(f.bytes, func(f protoField) error {
+					switch f.num {
+					case 1:
+						pattern.Kind = f.string()
+					case 2:
+						pattern.Measure = f.int()
+					case 3:
+						pattern.Beat = f.double()
+					}
+					return nil
+				})
+				patterns.Occurrences = append(patterns.Occurrences, pattern)
+				return err
+			}
+			return nil
+		})
+	case 3:
+		parity := &a.Parity
+		counts := []*int{&parity.Crossovers, &parity.Footswitches, &parity.Doublesteps, &parity.Jacks, &parity.Brackets}
+		return decodeProto(f.bytes, func(f protoField) error {
+			switch {
+			case f.num >= 1 && f.num <= 5:
+				*counts[f.num-1] = f.int()
+			case f.num == 6:
+				parity.Cost = f.double()
+			}
+			return nil
+		})
+	case 4:
+		d := &a.Difficulty
+		values := []*float64{&d.Overall, &d.Stream, &d.Jumpstream, &d.Handstream, &d.Stamina, &d.Jackspeed, &d.Chordjack, &d.Technical}
+		return decodeProto(f.bytes, func(f protoField) error {
+			if f.num >= 1 && f.num <= 8 {
+				*values[f.num-1] = f.double()
+			}
+			return nil
+		})
+	}
+	return nil
+}
+
+// protoEncoder appends protobuf fields, leaving out proto3 default values.
+type protoEncoder []byte
+
+func (e *protoEncoder) string(num protowire.Number, value string) {
+	if value != "" {
+		*e = protowire.AppendTag(*e, num, protowire.BytesType)
+		*e = protowire.AppendString(*e, value)
+	}
+}
+
+func (e *protoEncoder) double(num protowire.Number, value float64) {
+	if bits := math.Float64bits(value); bits != 0 {
+		*e = protowire.AppendTag(*e, num, protowire.Fixed64Type)
+		*e = protowire.AppendFixed64(*e, bits)
+	}
+}
+
+// int appends an int32 field, which encodes negative values in 10 bytes.
+func (e *protoEncoder) int(num protowire.Number, value int) {
+	if value != 0 {
+		*e = protowire.AppendTag(*e, num, protowire.VarintType)
+		*e = protowire.AppendVarint(*e, uint64(int64(int32(value))))
+	}
+}
+
+func (e *protoEncoder) uint(num protowire.Number, value int) {
+	if value != 0 {
+		*e = protowire.AppendTag(*e, num, protowire.VarintType)
+		*e = protowire.AppendVarint(*e, uint64(uint32(value)))
+	}
+}
+
+// doubles appends a packed repeated double field.
+func (e *protoEncoder) doubles(num protowire.Number, values []float64) {
+	if len(values) == 0 {
+		return
+	}
+	packed := make([]byte, 0, 8*len(values))
+	for _, value := range values {
+		packed = protowire.AppendFixed64(packed, math.Float64bits(value))
+	}
+	*e = protowire.AppendTag(*e, num, protowire.BytesType)
+	*e = protowire.AppendBytes(*e, packed)
+}
+
+// ints appends a packed repeated int32 field.
+func (e *protoEncoder) ints(num protowire.Number, values []int) {
+	if len(values) == 0 {
+		return
+	}
+	packed := []byte{}
+	for _, value := range values {
+		packed = protowire.AppendVarint(packed, uint64(int64(int32(value))))
+	}
+	*e = protowire.AppendTag(*e, num, protowire.BytesType)
+	*e = protowire.AppendBytes(*e, packed)
+}
+
+// message appends an embedded message field.
+func (e *protoEncoder) message(num protowire.Number, encode func(e *protoEncoder)) {
+	m := protoEncoder{}
+	encode(&m)
+	*e = protowire.AppendTag(*e, num, protowire.BytesType)
+	*e = protowire.AppendBytes(*e, m)
+}
+
+// protoField is a decoded protobuf field: bytes for length-delimited fields, and bits for
+// varint and fixed-size fields.
+type protoField struct {
+	num   protowire.Number
+	typ   protowire.Type
+	bytes []byte
+	bits  uint64
+}
+
+// decodeProto calls decode for each field of a message.
+func decodeProto(data []byte, decode func(f protoField) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		f := protoField{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.bits, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed64Type:
+			f.bits, n = protowire.ConsumeFixed64(data)
+		case protowire.Fixed32Type:
+			var bits uint32
+			bits, n = protowire.ConsumeFixed32(data)
+			f.bits = uint64(bits)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+		if err := decode(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f protoField) string() string {
+	return string(f.bytes)
+}
+
+func (f protoField) double() float64 {
+	if f.typ != protowire.Fixed64Type {
+		return 0
+	}
+	return math.Float64frombits(f.bits)
+}
+
+func (f protoField) int() int {
+	if f.typ != protowire.VarintType {
+		return 0
+	}
+	return int(int32(f.bits))
+}
+
+// appendDoubles decodes a packed or unpacked repeated double field.
+func (f protoField) appendDoubles(values *[]float64) error {
+	if f.typ == protowire.Fixed64Type {
+		*values = append(*values, f.double())
+		return nil
+	}
+	for data := f.bytes; len(data) > 0; {
+		bits, n := protowire.ConsumeFixed64(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		*values = append(*values, math.Float64frombits(bits))
+		data = data[n:]
+	}
+	return nil
+}
+
+// appendInts decodes a packed or unpacked repeated int32 field.
+func (f protoField) appendInts(values *[]int) error {
+	if f.typ == protowire.VarintType {
+		*values = append(*values, f.int())
+		return nil
+	}
+	for data := f.bytes; len(data) > 0; {
+		bits, n := protowire.ConsumeVarint(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		*values = append(*values, int(int32(bits)))
+		data = data[n:]
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProtoRoundTrip(t *testing.T) {
+	sim, err := ParseFile("../testdata/sharpnelstreamz/bluearmy/bluearmy.sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := MarshalProto(sim)
+	decoded, err := UnmarshalProto(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	again, _ := MarshalProto(decoded)
+	if !bytes.Equal(data, again) {
+		t.Error("Decoded Simfile encoded differently.")
+	}
+	if !reflect.DeepEqual(decoded.Header, sim.Header) || !reflect.DeepEqual(decoded.Charts[0].Notes, sim.Charts[0].Notes) {
+		t.Error("Header or notes changed in the round trip.")
+	}
+	analysis := decoded.Charts[0].Analysis
+	if !reflect.DeepEqual(analysis.Parity, sim.Charts[0].Analysis.Parity) || !reflect.DeepEqual(analysis.Patterns.Counts, sim.Charts[0].Analysis.Patterns.Counts) ||
+		!reflect.DeepEqual(analysis.Density, sim.Charts[0].Analysis.Density) {
+		t.Error("Analysis changed in the round trip.")
+	}
+	if simJSON, _ := json.Marshal(sim); len(data) >= len(simJSON)/2 {
+		errorMsg := fmt.Sprintf("Expected an encoding under half the JSON size of %d, received: %d bytes", len(simJSON), len(data))
+		t.Error(errorMsg)
+	}
+}
+
+func TestProtoWireFormat(t *testing.T) {
+	sim := Simfile{
+		SchemaVersion: 1,
+		Header:        Header{Title: "A", BPMs: []BeatChange{{Beat: 0, Value: 120}}},
+		Charts:        []Chart{{Meter: -1}},
+	}
+	data, _ := MarshalProto(sim)
+	// schema_version=1, header{title="A", bpms{value=120}}, charts{meter=-1, groove_radar{}, analysis{...}}
+	expected := "0801" + "1a0f" + "0a0141" + "9a0109" + "11" + "0000000000005e40" +
+		"2217" + "28ffffffffffffffffff01" + "3200" + "4208" + "0a00" + "1200" + "1a00" + "2200"
+	if output := hex.EncodeToString(data); output != expected {
+		errorMsg := fmt.Sprintf("Expected %s, received: %s", expected, output)
+		t.Error(errorMsg)
+	}
+}
+
+func TestUnmarshalProto(t *testing.T) {
+	var tests = []struct {
+		data  string
+		title string
+		valid bool
+	}{
+		{"1a030a0141", "A", true},
+		// Unknown varint, fixed32 and bytes fields are skipped.
+		{"f80701" + "fd0701000000" + "fa070100" + "1a030a0141", "A", true},
+		// Unpacked display_bpm.
+		{"1a0a91010000000000000000", "", true},
+		{"1a05", "", false},
+		{"1a030a05", "", false},
+		{"ff", "", false},
+	}
+	for _, test := range tests {
+		data, _ := hex.DecodeString(test.data)
+		sim, err := UnmarshalProto(data)
+		if (err == nil) != test.valid || sim.Header.Title != test.title {
+			errorMsg := fmt.Sprintf("Expected %q (valid=%t) for %s, received: %q (%v)", test.title, test.valid, test.data, sim.Header.Title, err)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestProtoDelimited(t *testing.T) {
+	var stream bytes.Buffer
+	for _, title := range []string{"One", "Two"} {
+		if err := WriteProtoDelimited(&stream, Simfile{Header: Header{Title: title}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.Write([]byte{0x05, 0x0a})
+
+	reader := bufio.NewReader(&stream)
+	for _, title := range []string{"One", "Two"} {
+		sim, err := ReadProtoDelimited(reader)
+		if err != nil || sim.Header.Title != title {
+			errorMsg := fmt.Sprintf("Expected %s, received: %s (%v)", title, sim.Header.Title, err)
+			t.Error(errorMsg)
+		}
+	}
+	if _, err := ReadProtoDelimited(reader); err == nil || err == io.EOF {
+		t.Error("Expected an error for a truncated message.")
+	}
+	if _, err := ReadProtoDelimited(reader); err != io.EOF {
+		errorMsg := fmt.Sprintf("Expected io.EOF at the end of the stream, received: %v", err)
+		t.Error(errorMsg)
+	}
+}
+
+func TestReadProtoDelimitedMalformed(t *testing.T) {
+	var tests = [][]byte{
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		{0xff, 0xff, 0xff, 0x7f, 0x0a},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+	}
+
+	for _, test := range tests {
+		if _, err := ReadProtoDelimited(bufio.NewReader(bytes.NewReader(test))); err == nil || !strings.HasPrefix(err.Error(), "Proto Error") {
+			errorMsg := fmt.Sprintf("Expected a proto error for % x, received: %v", test, err)
+			t.Error(errorMsg)
+		}
+	}
+}
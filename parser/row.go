@@ -0,0 +1,245 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NoteKind is the kind of note on one column of a Row.
+type NoteKind uint8
+
+// Note kinds, in the order of their simfile characters "0", "1", "2", "3", "4", "M", "L", "F".
+const (
+	NoteEmpty NoteKind = iota
+	NoteTap
+	NoteHoldHead
+	NoteTail
+	NoteRollHead
+	NoteMine
+	NoteLift
+	NoteFake
+)
+
+// noteKindValues are the Step values of each NoteKind.
+var noteKindValues = []string{"0", "1", "2", "3", "4", "M", "L", "F"}
+
+// String returns the simfile character of a NoteKind.
+func (k NoteKind) String() string {
+	if int(k) < len(noteKindValues) {
+		return noteKindValues[k]
+	}
+	return "0"
+}
+
+// noteKindNames are the names of each NoteKind, for tables.
+var noteKindNames = []string{"empty", "tap", "hold_head", "tail", "roll_head", "mine", "lift", "fake"}
+
+// Name returns the lowercase name of a NoteKind, such as "hold_head".
+func (k NoteKind) Name() string {
+	if int(k) < len(noteKindNames) {
+		return noteKindNames[k]
+	}
+	return "empty"
+}
+
+// noteKind returns the NoteKind of a Step value. Unknown values are NoteEmpty.
+func noteKind(value string) NoteKind {
+	for kind, kindValue := range noteKindValues {
+		if value == kindValue {
+			return NoteKind(kind)
+		}
+	}
+	return NoteEmpty
+}
+
+// RowsPerMeasure is the resolution of Row.Index, the 192nd notes StepMania uses.
+const RowsPerMeasure = 192
+
+// Row is a compact note row: 4 bits of NoteKind and 2 bits of foot per column, for up to 16 columns.
+type Row struct {
+	// Index is the position of the row in 192nds of a measure from the start of the chart.
+	Index int32
+	feet  uint32
+	notes uint64
+}
+
+// Kind returns the NoteKind of a column.
+func (r Row) Kind(column int) NoteKind {
+	return NoteKind(r.notes >> (4 * uint(column)) & 0xf)
+}
+
+// SetKind sets the NoteKind of a column.
+func (r *Row) SetKind(column int, kind NoteKind) {
+	shift := 4 * uint(column)
+	r.notes = r.notes&^(0xf<<shift) | uint64(kind&0xf)<<shift
+}
+
+// Foot returns the foot SolveParity assigned to a column: 'L', 'R' or 0 for none.
+func (r Row) Foot(column int) byte {
+	switch r.feet >> (2 * uint(column)) & 0x3 {
+	case footLeft:
+		return 'L'
+	case footRight:
+		return 'R'
+	}
+	return 0
+}
+
+// SetFoot sets the foot of a column to 'L', 'R' or 0 for none.
+func (r *Row) SetFoot(column int, foot byte) {
+	shift := 2 * uint(column)
+	value := uint32(footNone)
+	switch foot {
+	case 'L':
+		value = footLeft
+	case 'R':
+		value = footRight
+	}
+	r.feet = r.feet&^(0x3<<shift) | value<<shift
+}
+
+// IsEmpty reports whether every column of the row is NoteEmpty.
+func (r Row) IsEmpty() bool {
+	return r.notes == 0
+}
+
+// Hits returns the number of columns with notes the player hits: taps, hold and roll heads, and lifts.
+func (r Row) Hits() int {
+	hits := 0
+	for notes := r.notes; notes != 0; notes >>= 4 {
+		switch NoteKind(notes & 0xf) {
+		case NoteTap, NoteHoldHead, NoteRollHead, NoteLift:
+			hits++
+		}
+	}
+	return hits
+}
+
+// Beat returns the beat of the row.
+func (r Row) Beat() float64 {
+	return float64(r.Index) * 4 / RowsPerMeasure
+}
+
+// Measure returns the measure number of the row.
+func (r Row) Measure() int {
+	return int(r.Index) / RowsPerMeasure
+}
+
+// CompactNotes is the note data of a chart as non-empty Rows.
+type CompactNotes struct {
+	Columns int
+	// Quantizations is the number of rows written in each measure, to convert back to Measures.
+	Quantizations []int
+	Rows          []Row
+}
+
+// CompactMeasures converts Measures to CompactNotes.
+//
+// Every quantization must divide RowsPerMeasure, since the rows of other measures, like 20ths,
+// do not fall on a Row.Index and would be moved or merged. Empty measures have quantization 0.
+func CompactMeasures(measures []Measure) (CompactNotes, error) {
+	notes := CompactNotes{Columns: 4, Quantizations: make([]int, len(measures)), Rows: []Row{}}
+	for _, measure := range measures {
+		if len(measure.Steps) > 0 {
+			notes.Columns = len(measure.Steps[0].Panels())
+			break
+		}
+	}
+	for m, measure := range measures {
+		if measure.Quantization == 0 && len(measure.Steps) == 0 {
+			// A note block ending in "," has an empty last measure.
+			continue
+		}
+		if measure.Quantization <= 0 || RowsPerMeasure%measure.Quantization != 0 {
+			return CompactNotes{}, fmt.Errorf("Row Error: measure %d has %d rows, which do not divide %d", measure.MeasureNumber, measure.Quantization, RowsPerMeasure)
+		}
+		notes.Quantizations[m] = measure.Quantization
+		for s, step := range measure.Steps {
+			row := stepRow(step, measure.MeasureNumber*RowsPerMeasure+s*RowsPerMeasure/measure.Quantization)
+			if !row.IsEmpty() {
+				notes.Rows = append(notes.Rows, row)
+			}
+		}
+	}
+	return notes, nil
+}
+
+// Measures converts CompactNotes back to Measures, writing each measure with its original quantization.
+func (c CompactNotes) Measures() []Measure {
+	measures := make([]Measure, len(c.Quantizations))
+	next := 0
+	for m, quantization := range c.Quantizations {
+		steps := make([]Step, quantization)
+		beatPart := 1.00 / float64(quantization)
+		for s := range steps {
+			steps[s] = rowStep(Row{}, calcBeat(m, beatPart, 4*s), c.Columns)
+		}
+		for ; next < len(c.Rows) && c.Rows[next].Measure() == m; next++ {
+			row := c.Rows[next]
+			s := ((int(row.Index)-m*RowsPerMeasure)*quantization + RowsPerMeasure/2) / RowsPerMeasure
+			steps[s] = rowStep(row, steps[s].Beat, c.Columns)
+		}
+		measures[m] = Measure{MeasureNumber: m, Quantization: quantization, Steps: steps}
+	}
+	return measures
+}
+
+// rowMeasures converts Rows sorted by index to Measures of a chart that is end rows long, giving
+// each measure the coarsest quantization that holds its rows.
+func rowMeasures(rows []Row, end int, columns int) []Measure {
+	notes := CompactNotes{Columns: columns, Quantizations: make([]int, (end+RowsPerMeasure-1)/RowsPerMeasure), Rows: rows}
+	for m := range notes.Quantizations {
+		notes.Quantizations[m] = 4
+	}
+	for _, row := range rows {
+		m := row.Measure()
+		notes.Quantizations[m] = lcm(notes.Quantizations[m], rowSnap(int(row.Index)-m*RowsPerMeasure, RowsPerMeasure))
+	}
+	return notes.Measures()
+}
+
+// lcm returns the least common multiple of two positive numbers.
+func lcm(a, b int) int {
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return a / x * b
+}
+
+// stepRow converts a Step to a Row at a 192nd row index.
+func stepRow(step Step, index int) Row {
+	row := Row{Index: int32(index)}
+	panels := step.Panels()
+	for column, value := range panels {
+		row.SetKind(column, noteKind(value))
+		if len(step.Feet) == len(panels) {
+			row.SetFoot(column, step.Feet[column])
+		}
+	}
+	return row
+}
+
+// rowStep converts a Row to a Step, using Step.Columns unless it has the 4 dance-single columns.
+func rowStep(row Row, beat float64, columns int) Step {
+	step := Step{Beat: beat}
+	if columns == 4 {
+		step.L, step.D, step.U, step.R = row.Kind(0).String(), row.Kind(1).String(), row.Kind(2).String(), row.Kind(3).String()
+	} else {
+		values := make([]byte, columns)
+		for column := range values {
+			values[column] = row.Kind(column).String()[0]
+		}
+		step.Columns = string(values)
+	}
+	if row.feet != 0 {
+		feet := []byte(strings.Repeat("-", columns))
+		for column := range feet {
+			if foot := row.Foot(column); foot != 0 {
+				feet[column] = foot
+			}
+		}
+		step.Feet = string(feet)
+	}
+	return step
+}
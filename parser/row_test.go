@@ -0,0 +1,129 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestRowKinds(t *testing.T) {
+	row := Row{}
+	row.SetKind(0, NoteTap)
+	row.SetKind(3, NoteMine)
+	row.SetKind(15, NoteFake)
+	row.SetKind(0, NoteHoldHead)
+
+	var kinds = map[int]NoteKind{0: NoteHoldHead, 1: NoteEmpty, 3: NoteMine, 15: NoteFake}
+	for column, kind := range kinds {
+		if output := row.Kind(column); output != kind {
+			errorMsg := fmt.Sprintf("Expected %s in column %d, received: %s", kind, column, output)
+			t.Error(errorMsg)
+		}
+	}
+	if row.IsEmpty() {
+		t.Error("Row with notes reported empty.")
+	}
+}
+
+func TestRowFeet(t *testing.T) {
+	row := Row{}
+	row.SetFoot(1, 'L')
+	row.SetFoot(2, 'R')
+	row.SetFoot(2, 'L')
+	if row.Foot(0) != 0 || row.Foot(1) != 'L' || row.Foot(2) != 'L' {
+		t.Error("Row feet set incorrectly.")
+	}
+}
+
+func TestRowPosition(t *testing.T) {
+	row := Row{Index: 2*RowsPerMeasure + 48}
+	if row.Beat() != 9 || row.Measure() != 2 {
+		t.Error("Row position calculated incorrectly.")
+	}
+	if size := unsafe.Sizeof(row); size != 16 {
+		errorMsg := fmt.Sprintf("Expected a 16 byte Row, received: %d", size)
+		t.Error(errorMsg)
+	}
+}
+
+func TestTableNoteKind(t *testing.T) {
+	var tests = []struct {
+		value string
+		kind  NoteKind
+	}{
+		{"0", NoteEmpty},
+		{"1", NoteTap},
+		{"2", NoteHoldHead},
+		{"3", NoteTail},
+		{"4", NoteRollHead},
+		{"M", NoteMine},
+		{"L", NoteLift},
+		{"F", NoteFake},
+		{"K", NoteEmpty},
+	}
+
+	for _, test := range tests {
+		if output := noteKind(test.value); output != test.kind {
+			errorMsg := fmt.Sprintf("Expected %s for %s, received: %s", test.kind, test.value, output)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestCompactMeasures(t *testing.T) {
+	measures := noteData("1000000001000000,10000000000000000000M000,0000," + strings.Repeat("0000", 15) + "0001")
+	SolveParity(measures, NewTiming(Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 120}}}))
+	notes, err := CompactMeasures(measures)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(notes.Rows) != 5 {
+		errorMsg := fmt.Sprintf("Expected 5 rows, received: %d", len(notes.Rows))
+		t.Error(errorMsg)
+	}
+	if notes.Rows[1].Index != 96 || notes.Rows[3].Kind(0) != NoteMine || notes.Rows[4].Index != 3*RowsPerMeasure+180 {
+		t.Error("Rows compacted incorrectly.")
+	}
+	if output := notes.Measures(); !reflect.DeepEqual(output, measures) {
+		t.Error("Measures changed in a round trip through CompactNotes.")
+	}
+}
+
+func TestCompactMeasuresTestdata(t *testing.T) {
+	sim, _ := ParseFile("../testdata/sharpnelstreamz/bluearmy/bluearmy.sm")
+	for _, chart := range sim.Charts {
+		notes, err := CompactMeasures(chart.Notes)
+		if output := notes.Measures(); err != nil || (len(chart.Notes) > 0 && !reflect.DeepEqual(output, chart.Notes)) {
+			t.Error("Chart changed in a round trip through CompactNotes.")
+		}
+	}
+}
+
+func TestCompactMeasuresEmptyMeasure(t *testing.T) {
+	sim, _ := Parse([]byte("#NOTES:dance-single:::1:0,0,0,0,0:1000\n,\n;"))
+	measures := sim.Charts[0].Notes
+	notes, err := CompactMeasures(measures)
+	if err != nil || !reflect.DeepEqual(notes.Quantizations, []int{1, 0}) || !reflect.DeepEqual(notes.Measures(), measures) {
+		errorMsg := fmt.Sprintf("Expected the empty last measure to round trip, received: %v (%v)", notes.Quantizations, err)
+		t.Error(errorMsg)
+	}
+}
+
+func TestCompactMeasuresQuantization(t *testing.T) {
+	var tests = []string{
+		strings.Repeat("1000", 20),
+		strings.Repeat("0100", 28),
+		strings.Repeat("0010", 384),
+	}
+
+	for _, test := range tests {
+		measures := noteData(test)
+		if _, err := CompactMeasures(measures); err == nil || !strings.HasPrefix(err.Error(), "Row Error") {
+			errorMsg := fmt.Sprintf("Expected a row error for quantization %d, received: %v", measures[0].Quantization, err)
+			t.Error(errorMsg)
+		}
+	}
+}
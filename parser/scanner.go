@@ -0,0 +1,202 @@
+package parser
+
+import "bytes"
+
+// Scanner reads the tags of a simfile in a single pass, without copying the data.
+//
+// Tags look like "#NAME:VALUE;". Comments starting with "//" are skipped between tags, and a
+// "#" at the start of a line ends a tag missing its ";", as in StepMania.
+type Scanner struct {
+	data  []byte
+	pos   int
+	name  []byte
+	value []byte
+}
+
+// NewScanner returns a Scanner over the contents of a simfile.
+func NewScanner(data []byte) *Scanner {
+	return &Scanner{data: data}
+}
+
+// Next advances to the next tag, returning false at the end of the data.
+func (s *Scanner) Next() bool {
+	data := s.data
+	for s.pos < len(data) {
+		switch {
+		case data[s.pos] == '#':
+			return s.readTag()
+		case isComment(data, s.pos):
+			s.pos = lineEnd(data, s.pos)
+		default:
+			s.pos++
+		}
+	}
+	return false
+}
+
+// Name returns the name of the current tag, such as "TITLE".
+func (s *Scanner) Name() []byte {
+	return s.name
+}
+
+// Value returns the raw value of the current tag, up to its ";".
+func (s *Scanner) Value() []byte {
+	return s.value
+}
+
+// readTag reads the tag starting at the current "#".
+func (s *Scanner) readTag() bool {
+	data := s.data
+	start := s.pos + 1
+	colon := start
+	for colon < len(data) && data[colon] != ':' && data[colon] != ';' {
+		colon++
+	}
+	s.name = data[start:colon]
+	if colon == len(data) || data[colon] == ';' {
+		s.value = data[colon:colon]
+		s.pos = colon + 1
+		return true
+	}
+
+	end := len(data)
+	if semicolon := bytes.IndexByte(data[colon+1:], ';'); semicolon >= 0 {
+		end = colon + 1 + semicolon
+	}
+	for hash := colon + 1; hash < end; hash++ {
+		next := bytes.IndexByte(data[hash:end], '#')
+		if next < 0 {
+			break
+		}
+		hash += next
+		if startsLine(data, hash) {
+			s.value = data[colon+1 : hash]
+			s.pos = hash
+			return true
+		}
+	}
+	s.value = data[colon+1 : end]
+	s.pos = end + 1
+	return true
+}
+
+// startsLine reports whether only spaces are between pos and the previous newline.
+func startsLine(data []byte, pos int) bool {
+	for pos--; pos >= 0; pos-- {
+		switch data[pos] {
+		case '\n':
+			return true
+		case ' ', '\t', '\r':
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// NoteScanner reads the rows of a chart's note block in a single pass.
+//
+// Whitespace and "//" comments are skipped, "," ends a measure and ";" ends the block.
+type NoteScanner struct {
+	data    []byte
+	pos     int
+	panels  int
+	measure int
+	row     []byte
+	buf     []byte
+}
+
+// NewNoteScanner returns a NoteScanner over a note block with the given number of panels per row.
+func NewNoteScanner(notes []byte, panels int) *NoteScanner {
+	return &NoteScanner{data: notes, panels: panels, measure: -1}
+}
+
+// Next advances to the next row or measure, returning false at the end of the block.
+//
+// Every measure, including the first, starts with a call where Row returns nil.
+func (n *NoteScanner) Next() bool {
+	if n.measure < 0 {
+		n.measure, n.row = 0, nil
+		return true
+	}
+	if !n.skipSpace() {
+		return false
+	}
+	if n.data[n.pos] == ',' {
+		n.pos++
+		n.measure++
+		n.row = nil
+		return true
+	}
+
+	// Rows are usually contiguous, so they can be returned without copying.
+	end := n.pos + n.panels
+	if end <= len(n.data) && isNoteRun(n.data[n.pos:end]) {
+		n.row = n.data[n.pos:end]
+		n.pos = end
+		return true
+	}
+
+	n.buf = n.buf[:0]
+	for len(n.buf) < n.panels && n.skipSpace() && n.data[n.pos] != ',' {
+		n.buf = append(n.buf, n.data[n.pos])
+		n.pos++
+	}
+	if len(n.buf) < n.panels {
+		return n.Next()
+	}
+	n.row = n.buf
+	return true
+}
+
+// Row returns the note values of the current row, or nil at the start of a measure.
+func (n *NoteScanner) Row() []byte {
+	return n.row
+}
+
+// Measure returns the measure number of the current row.
+func (n *NoteScanner) Measure() int {
+	return n.measure
+}
+
+// skipSpace moves to the next note value, "," or ";", returning false at the end of the block.
+func (n *NoteScanner) skipSpace() bool {
+	data := n.data
+	for n.pos < len(data) {
+		switch c := data[n.pos]; {
+		case c == ';':
+			n.pos = len(data)
+			return false
+		case isComment(data, n.pos):
+			n.pos = lineEnd(data, n.pos)
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			n.pos++
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// isNoteRun reports whether a slice holds only note values.
+func isNoteRun(values []byte) bool {
+	for i, c := range values {
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == ',' || c == ';' || isComment(values, i) {
+			return false
+		}
+	}
+	return true
+}
+
+// isComment reports whether a "//" comment starts at pos.
+func isComment(data []byte, pos int) bool {
+	return data[pos] == '/' && pos+1 < len(data) && data[pos+1] == '/'
+}
+
+// lineEnd returns the position of the end of the line containing pos.
+func lineEnd(data []byte, pos int) int {
+	for pos < len(data) && data[pos] != '\n' {
+		pos++
+	}
+	return pos
+}
@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	data := "#TITLE:Song Title;\r\n// #ARTIST:Commented;\n#BPMS:0=120\n,4=150;#EMPTY;\n#MISSING:value\n  #CREDIT:me;"
+	var tags = []struct {
+		name  string
+		value string
+	}{
+		{"TITLE", "Song Title"},
+		{"BPMS", "0=120\n,4=150"},
+		{"EMPTY", ""},
+		{"MISSING", "value\n  "},
+		{"CREDIT", "me"},
+	}
+
+	scanner := NewScanner([]byte(data))
+	for _, tag := range tags {
+		if !scanner.Next() {
+			t.Fatal("Scanner ended early.")
+		}
+		if string(scanner.Name()) != tag.name || string(scanner.Value()) != tag.value {
+			errorMsg := fmt.Sprintf("Expected %s:%q, received: %s:%q", tag.name, tag.value, scanner.Name(), scanner.Value())
+			t.Error(errorMsg)
+		}
+	}
+	if scanner.Next() {
+		t.Error("Scanner did not end.")
+	}
+}
+
+func TestNoteScanner(t *testing.T) {
+	notes := "\r\n1000\r\n0100 // first\r\n,\r\n,\r\n00\n10\r\n0001;ignored"
+	var rows = []struct {
+		measure int
+		row     string
+	}{
+		{0, ""},
+		{0, "1000"},
+		{0, "0100"},
+		{1, ""},
+		{2, ""},
+		{2, "0010"},
+		{2, "0001"},
+	}
+
+	scanner := NewNoteScanner([]byte(notes), 4)
+	for _, row := range rows {
+		if !scanner.Next() {
+			t.Fatal("NoteScanner ended early.")
+		}
+		if scanner.Measure() != row.measure || string(scanner.Row()) != row.row {
+			errorMsg := fmt.Sprintf("Expected %d:%s, received: %d:%s", row.measure, row.row, scanner.Measure(), scanner.Row())
+			t.Error(errorMsg)
+		}
+	}
+	if scanner.Next() {
+		t.Error("NoteScanner did not end.")
+	}
+}
+
+func TestDecodeMeasures(t *testing.T) {
+	measures := decodeMeasures([]byte("1000\n0100\n0010\n0001\n,\n10000000\n,"))
+	if len(measures) != 3 {
+		t.Fatal(fmt.Sprintf("Expected 3 measures, received: %d", len(measures)))
+	}
+	if measures[0].Quantization != 4 || measures[1].Quantization != 2 || measures[2].Quantization != 0 {
+		t.Error("Measures quantized incorrectly.")
+	}
+	if measures[1].Steps[0].Beat != 4 || measures[1].Steps[0].L != "1" || measures[1].Steps[1].Beat != 6 {
+		t.Error("Steps decoded incorrectly.")
+	}
+}
+
+// legacyNoteData is the strings based decoder the NoteScanner replaced, kept for benchmarks.
+func legacyNoteData(notes string) []Measure {
+	measureSlices := []Measure{}
+	measures := strings.SplitAfter(notes, ",")
+	for measureNumber, measureString := range measures {
+		measureClean := strings.Replace(strings.Replace(measureString, "\r", "", -1), ",", "", -1)
+		quantization := len(measureClean) / 4
+		steps := []Step{}
+		step := Step{}
+		beatPart := 1.00 / float64(quantization)
+		for i, m := range measureClean {
+			switch remainder := math.Mod(float64(i+1), 4); remainder {
+			case 1:
+				step.L = string(m)
+			case 2:
+				step.D = string(m)
+			case 3:
+				step.U = string(m)
+			case 0:
+				step.R = string(m)
+				step.Beat = calcBeat(measureNumber, beatPart, i)
+				steps = append(steps, step)
+				step = Step{}
+			}
+		}
+		measureSlices = append(measureSlices, Measure{MeasureNumber: measureNumber, Quantization: quantization, Steps: steps})
+	}
+	return measureSlices
+}
+
+func BenchmarkScanner(b *testing.B) {
+	for _, smPath := range benchmarkFiles {
+		data, _ := ReadSM(smPath)
+		b.Run(PackName(smPath)+"/"+path.Base(smPath), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				scanner := NewScanner(data)
+				sim := Simfile{}
+				for scanner.Next() {
+					if string(scanner.Name()) != "NOTES" {
+						sim = extractHeaderTag(scanner.Name(), scanner.Value(), sim)
+						continue
+					}
+					notes, block, _ := noteFields(scanner.Value())
+					if notes[1] == "dance-single" {
+						decodeMeasures(block)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSplit(b *testing.B) {
+	for _, smPath := range benchmarkFiles {
+		data, _ := ReadSM(smPath)
+		b.Run(PackName(smPath)+"/"+path.Base(smPath), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				sim := Simfile{}
+				for _, tag := range strings.Split(string(data), ";") {
+					sim = ExtractHeader(tag, sim)
+				}
+				for _, chart := range sim.Charts {
+					notes := RawNoteValue(chart.RawData)
+					if notes[1] == "dance-single" {
+						legacyNoteData(notes[6])
+					}
+				}
+			}
+		})
+	}
+}
+
+var benchmarkFiles = []string{
+	"../testdata/sharpnelstreamz/bluearmy/bluearmy.sm",
+	"../testdata/sharpnelstreamz/200312023/twothousand.sm",
+}
@@ -0,0 +1,220 @@
+package parser
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// SchemaVersion is the version of the JSON written by WriteJSON, stored in Simfile.SchemaVersion.
+//
+// It is bumped whenever a field is removed, renamed or changes meaning, and a migration from
+// the previous version is added to MigrateJSON. Adding a field does not bump it. JSON written
+// before versioning has no schema_version and is version 0.
+const SchemaVersion = 1
+
+// schemaDocs describes the types and fields of the JSON output, keyed by "Type" and
+// "Type.json_name".
+var schemaDocs = map[string]string{
+	"Simfile":                "A parsed simfile.",
+	"Simfile.schema_version": "Version of this JSON model. See SchemaVersion.",
+	"Simfile.song_pack":      "Name of the pack folder containing the song folder.",
+	"Simfile.header":         "Song metadata from the header tags.",
+	"Simfile.charts":         "Charts in file order. dance-single charts are parsed, and pump-single and pump-double charts of .ksf and .ucs files; other charts are empty objects.",
+
+	"Header":                   "Song metadata from the header tags of a simfile.",
+	"Header.title":             "#TITLE",
+	"Header.subtitle":          "#SUBTITLE",
+	"Header.artist":            "#ARTIST",
+	"Header.title_translit":    "#TITLETRANSLIT",
+	"Header.subtitle_translit": "#SUBTITLETRANSLIT",
+	"Header.artist_translit":   "#ARTISTTRANSLIT",
+	"Header.genre":             "#GENRE",
+	"Header.credit":            "#CREDIT",
+	"Header.banner":            "#BANNER image path, relative to the song folder.",
+	"Header.background":        "#BACKGROUND image path, relative to the song folder.",
+	"Header.lyrics_path":       "#LYRICSPATH, relative to the song folder.",
+	"Header.cd_title":          "#CDTITLE image path, relative to the song folder.",
+	"Header.music":             "#MUSIC audio path, relative to the song folder.",
+	"Header.offset":            "#OFFSET in seconds: the time of beat 0 is -offset.",
+	"Header.sample_start":      "#SAMPLESTART in seconds.",
+	"Header.sample_length":     "#SAMPLELENGTH in seconds.",
+	"Header.selectable":        "#SELECTABLE, such as YES or NO.",
+	"Header.display_bpm":       "#DISPLAYBPM: [bpm] for one value, [low, high] for a range, and [0] for \"*\" (random). Null when the tag is missing.",
+	"Header.bpms":              "#BPMS: the value is the BPM from the beat on. Null when the tag is missing.",
+	"Header.stops":             "#STOPS: the value is the pause in seconds at the beat. Null when the tag is missing.",
+	"Header.delays":            "#DELAYS: the value is the pause in seconds before the notes at the beat. Null when the tag is missing.",
+	"Header.bg_changes":        "#BGCHANGES beats. Values are not parsed.",
+	"Header.keysounds":         "#KEYSOUNDS beats. Values are not parsed.",
+
+	"BeatChange":       "A value that takes effect at a beat.",
+	"BeatChange.beat":  "Beat, counted in quarter notes from beat 0 of the song.",
+	"BeatChange.value": "Value at the beat.",
+
+	"Chart":             "A chart of a simfile.",
+	"Chart.raw_data":    "Undecoded note data. Only set when parsed in header-only mode.",
+	"Chart.type":        "Steps type, such as dance-single. Empty for charts that were not parsed.",
+	"Chart.description": "Author or description of the chart.",
+	"Chart.difficulty":  "Difficulty slot: Beginner, Easy, Medium, Hard, Challenge or Edit.",
+	"Chart.meter":       "Authored difficulty rating.",
+	"Chart.grooveradar": "Authored Groove Radar values, often all 0.",
+	"Chart.notes":       "Measures in order.",
+	"Chart.analysis":    "Analysis computed by the parser from the notes.",
+
+	"Radar": "The 5 Groove Radar attributes, from 0 to 1.",
+
+	"Measure":              "A measure of 4 beats.",
+	"Measure.measure_nbr":  "Index of the measure, from 0.",
+	"Measure.quantization": "Number of rows in the measure, such as 4 for quarter notes or 16 for sixteenths.",
+	"Measure.steps":        "Rows of the measure, evenly spaced.",
+
+	"Step":         "A row of notes.",
+	"Step.beat":    "Beat of the row, counted in quarter notes from beat 0 of the song: measure_nbr * 4 + 4 * row / quantization.",
+	"Step.l":       "Left panel note: 0 none, 1 tap, 2 hold head, 3 hold or roll tail, 4 roll head, M mine, L lift, F fake.",
+	"Step.d":       "Down panel note, as for l.",
+	"Step.u":       "Up panel note, as for l.",
+	"Step.r":       "Right panel note, as for l.",
+	"Step.feet":    "Foot hitting each panel in l, d, u, r order from the parity solver: L, R, or - for none. Omitted for empty rows.",
+	"Step.columns": "Notes of charts without the four dance panels, such as pump-single, one character per column with the values of l. Omitted for dance-single charts, whose l, d, u and r are set instead.",
+
+	"Analysis":              "Analysis computed by the parser from the notes.",
+	"Density":               "Note density, counting rows with notes to hit.",
+	"Density.per_measure":   "Notes per second of each measure.",
+	"Density.per_second":    "Notes in each second of the song, from 0 seconds.",
+	"Density.peak_nps":      "Highest notes per second of a measure.",
+	"Density.peak_measure":  "Index of the measure with the highest notes per second.",
+	"Density.peak_seconds":  "Time of the start of the peak measure in seconds.",
+	"Patterns":              "Step patterns found in the chart.",
+	"Patterns.counts":       "Number of occurrences of each pattern kind.",
+	"Patterns.occurrences":  "Every pattern found, in time order.",
+	"Pattern.kind":          "Pattern kind, such as jack, crossover or drill.",
+	"Pattern.measure_nbr":   "Index of the measure where the pattern starts.",
+	"Pattern.beat":          "Beat where the pattern starts.",
+	"Parity":                "Foot placement found by the parity solver.",
+	"Parity.cost":           "Total cost of the foot placement; higher is more awkward.",
+	"Difficulty":            "Difficulty estimated from the notes, on the scale of the authored meter.",
+	"Difficulty.overall":    "Overall estimated difficulty.",
+	"Difficulty.stamina":    "Difficulty of the hardest minute of the chart.",
+	"Difficulty.technical":  "Difficulty of crossovers, footswitches and brackets.",
+	"Difficulty.jackspeed":  "Difficulty of repeated notes on one panel.",
+	"Difficulty.chordjack":  "Difficulty of repeated jumps and hands.",
+	"Difficulty.stream":     "Difficulty of single-note streams.",
+	"Difficulty.jumpstream": "Difficulty of streams with jumps.",
+	"Difficulty.handstream": "Difficulty of streams with hands.",
+}
+
+// JSONSchema returns a JSON Schema (draft 2020-12) document describing the JSON written by
+// WriteJSON.
+func JSONSchema() ([]byte, error) {
+	defs := map[string]interface{}{}
+	root := schemaFor(reflect.TypeOf(Simfile{}), defs)
+	defs["Simfile"].(map[string]interface{})["properties"].(map[string]interface{})["schema_version"].(map[string]interface{})["const"] = SchemaVersion
+
+	schema := map[string]interface{}{
+		"$schema":     "https://json-schema.org/draft/2020-12/schema",
+		"title":       "go-sm-parser Simfile",
+		"description": fmt.Sprintf("JSON written by WriteJSON, schema version %d.", SchemaVersion),
+		"$ref":        root["$ref"],
+		"$defs":       defs,
+	}
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// schemaFor returns the schema of a Go type, adding named structs to defs.
+func schemaFor(t reflect.Type, defs map[string]interface{}) map[string]interface{} {
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": []string{"array", "null"}, "items": schemaFor(t.Elem(), defs)}
+	case reflect.Map:
+		return map[string]interface{}{"type": []string{"object", "null"}, "additionalProperties": schemaFor(t.Elem(), defs)}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/$defs/" + t.Name()}
+		if _, ok := defs[t.Name()]; ok {
+			return ref
+		}
+		def := map[string]interface{}{"type": "object"}
+		defs[t.Name()] = def
+		if doc, ok := schemaDocs[t.Name()]; ok {
+			def["description"] = doc
+		}
+
+		properties := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+			property := schemaFor(field.Type, defs)
+			if doc, ok := schemaDocs[t.Name()+"."+name]; ok {
+				if property["$ref"] != nil {
+					property = map[string]interface{}{"allOf": []interface{}{property}}
+				}
+				property["description"] = doc
+			}
+			properties[name] = property
+			if options != "omitempty" {
+				required = append(required, name)
+			}
+		}
+		def["properties"] = properties
+		def["required"] = required
+		return ref
+	}
+	panic(fmt.Sprintf("Schema Error: unsupported type %s", t))
+}
+
+// MigrateJSON upgrades JSON written by WriteJSON with an older schema version to SchemaVersion.
+//
+// JSON newer than SchemaVersion is returned as an error, as it may use fields this version
+// does not understand.
+func MigrateJSON(data []byte) ([]byte, error) {
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("Schema Error: %v", err)
+	}
+	version := 0
+	if value, ok := doc["schema_version"]; ok {
+		number, isNumber := value.(float64)
+		if !isNumber || number != float64(int(number)) || number < 0 {
+			return nil, fmt.Errorf("Schema Error: invalid schema_version %v", value)
+		}
+		version = int(number)
+	}
+	if version > SchemaVersion {
+		return nil, fmt.Errorf("Schema Error: schema version %d is newer than %d", version, SchemaVersion)
+	}
+	if version == SchemaVersion {
+		return data, nil
+	}
+
+	for ; version < SchemaVersion; version++ {
+		migrations[version](doc)
+	}
+	doc["schema_version"] = SchemaVersion
+	return json.Marshal(doc)
+}
+
+// migrations upgrade a JSON document from the version at their index to the next version.
+var migrations = []func(doc map[string]interface{}){
+	// Version 0 has no analysis or feet and is otherwise the same as version 1.
+	func(doc map[string]interface{}) {},
+}
@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+func TestJSONSchemaDocument(t *testing.T) {
+	schema, err := JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	document, err := ioutil.ReadFile("../docs/schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(schema, document) {
+		t.Error("docs/schema.json is out of date. Regenerate it with: smparser schema > docs/schema.json")
+	}
+}
+
+func TestJSONSchemaFields(t *testing.T) {
+	schema, _ := JSONSchema()
+	var doc struct {
+		Defs map[string]struct {
+			Properties map[string]map[string]interface{} `json:"properties"`
+			Required   []string                          `json:"required"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(schema, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		def      string
+		property string
+	}{
+		{"Simfile", "schema_version"},
+		{"Header", "display_bpm"},
+		{"Measure", "measure_nbr"},
+		{"Step", "beat"},
+		{"Step", "feet"},
+		{"Analysis", "difficulty"},
+	}
+	for _, test := range tests {
+		if _, ok := doc.Defs[test.def].Properties[test.property]; !ok {
+			errorMsg := fmt.Sprintf("Expected %s.%s in the schema.", test.def, test.property)
+			t.Error(errorMsg)
+		}
+	}
+	if len(doc.Defs["Step"].Required) != 5 {
+		t.Error("Optional Step fields marked required.")
+	}
+}
+
+func TestTableMigrateJSON(t *testing.T) {
+	var tests = []struct {
+		data    string
+		version int
+		valid   bool
+	}{
+		{`{"song_pack":"Pack","header":{},"charts":[]}`, SchemaVersion, true},
+		{`{"schema_version":0,"song_pack":"Pack"}`, SchemaVersion, true},
+		{`{"schema_version":1,"song_pack":"Pack"}`, SchemaVersion, true},
+		{`{"schema_version":99}`, 0, false},
+		{`{"schema_version":"1"}`, 0, false},
+		{`{"schema_version":1.5}`, 0, false},
+		{`[]`, 0, false},
+	}
+	for _, test := range tests {
+		migrated, err := MigrateJSON([]byte(test.data))
+		if (err == nil) != test.valid {
+			errorMsg := fmt.Sprintf("Expected valid=%t for %s, received: %v", test.valid, test.data, err)
+			t.Error(errorMsg)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		doc := map[string]interface{}{}
+		json.Unmarshal(migrated, &doc)
+		if doc["schema_version"] != float64(test.version) || doc["song_pack"] != "Pack" {
+			errorMsg := fmt.Sprintf("Expected version %d for %s, received: %s", test.version, test.data, migrated)
+			t.Error(errorMsg)
+		}
+	}
+}
@@ -4,15 +4,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
+	"os"
 	"path"
+	"path/filepath"
+	"strings"
 )
 
 // Simfile represents a single Stepmania simfile.
 type Simfile struct {
-	SongPack string  `json:"song_pack"`
-	Header   Header  `json:"header"`
-	Charts   []Chart `json:"charts"`
+	SchemaVersion int     `json:"schema_version"`
+	SongPack      string  `json:"song_pack"`
+	Header        Header  `json:"header"`
+	Charts        []Chart `json:"charts"`
 }
 
 // PackName extracts the pack name from the parent directory of the song folder.
@@ -25,17 +30,223 @@ func PackName(smDir string) string {
 // ReadSM returns a byte array from a .sm file
 func ReadSM(smPath string) ([]uint8, error) {
 	if path.Ext(smPath) == ".sm" {
-		data, _ := ioutil.ReadFile(smPath)
-		return data, nil
+		return ioutil.ReadFile(smPath)
 	}
 	return nil, errors.New("Extension Error: File is not of type .sm")
 }
 
-// WriteJSON serializes parsed Simfile data as JSON.
+// ParseOptions configures ParseWith.
+type ParseOptions struct {
+	// HeaderOnly skips decoding note data, which is left in Chart.RawData for DecodeNotes.
+	HeaderOnly bool
+}
+
+// ReadSMFS returns a byte array from a .sm file in a file system, such as a zip archive.
+func ReadSMFS(fsys fs.FS, name string) ([]uint8, error) {
+	if path.Ext(name) == ".sm" {
+		return fs.ReadFile(fsys, name)
+	}
+	return nil, errors.New("Extension Error: File is not of type .sm")
+}
+
+// Parse parses the header tags and charts of a .sm file.
+func Parse(data []byte) (Simfile, error) {
+	return ParseWith(data, ParseOptions{})
+}
+
+// ParseWith parses the header tags and charts of a .sm file.
+//
+// Malformed values that would make CheckError panic are returned as an error instead.
+func ParseWith(data []byte, options ParseOptions) (sim Simfile, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			sim, err = Simfile{}, fmt.Errorf("Parse Error: %v", r)
+		}
+	}()
+
+	sim.SchemaVersion = SchemaVersion
+
+	// Parse the header tags, keeping the note blocks until the timing is known.
+	blocks := [][]byte{}
+	scanner := NewScanner(data)
+	for scanner.Next() {
+		if string(scanner.Name()) != "NOTES" {
+			sim = extractHeaderTag(scanner.Name(), scanner.Value(), sim)
+			continue
+		}
+		notes, block, err := noteFields(scanner.Value())
+		if err != nil {
+			return Simfile{}, err
+		}
+		sim.Charts = append(sim.Charts, Chart{})
+		if notes[1] != "dance-single" {
+			block = nil
+		} else {
+			sim = extractChartHeader(len(sim.Charts)-1, notes, sim)
+		}
+		blocks = append(blocks, block)
+	}
+
+	// Parse the notes tag (chart data).
+	for i, block := range blocks {
+		switch {
+		case block == nil:
+		case options.HeaderOnly:
+			sim.Charts[i].RawData = string(block)
+		default:
+			sim.Charts[i].Notes = decodeMeasures(block)
+			sim.Charts[i].Analysis = Analyze(sim.Charts[i], sim.Header)
+		}
+	}
+	return sim, nil
+}
+
+// formatParsers parse each simfile format ParseFile supports, by lowercase extension.
+var formatParsers = map[string]func([]byte, ParseOptions) (Simfile, error){
+	".sm":  ParseWith,
+	".dwi": ParseDWIWith,
+	".ucs": ParseUCSWith,
+}
+
+// Parsable reports whether ParseFile reads a file, from its extension.
+func Parsable(name string) bool {
+	return formatParsers[strings.ToLower(path.Ext(name))] != nil || isKSF(name)
+}
+
+// isKSF reports whether a file is a .ksf chart, which ParseFile reads with the other charts of
+// its song folder.
+func isKSF(name string) bool {
+	return strings.EqualFold(path.Ext(name), ".ksf")
+}
+
+// readSimfile reads a file with read when ParseFile supports its format.
+func readSimfile(name string, read func(string) ([]byte, error)) ([]byte, error) {
+	if Parsable(name) {
+		return read(name)
+	}
+	return nil, errors.New("Extension Error: File is not of type .sm, .dwi, .ksf or .ucs")
+}
+
+// ParseFile reads and parses a .sm, .dwi or .ucs file, naming its pack after the parent of the song folder.
+// A .ksf file is parsed with the other .ksf files of its song folder by ParseKSF.
+func ParseFile(smPath string) (Simfile, error) {
+	return ParseFileWith(smPath, ParseOptions{})
+}
+
+// ParseFileWith reads and parses a .sm file with ParseWith, a .dwi file with ParseDWIWith, a
+// .ucs file with ParseUCSWith, or the song folder of a .ksf file with ParseKSFWith.
+func ParseFileWith(smPath string, options ParseOptions) (Simfile, error) {
+	if isKSF(smPath) {
+		return parseKSFNamed(os.DirFS(filepath.Dir(smPath)), ".", smPath, options)
+	}
+	data, err := readSimfile(smPath, ioutil.ReadFile)
+	return parseNamed(data, err, smPath, options)
+}
+
+// ParseFS reads and parses a simfile in a file system, such as a zip archive.
+func ParseFS(fsys fs.FS, name string) (Simfile, error) {
+	return ParseFSWith(fsys, name, ParseOptions{})
+}
+
+// ParseFSWith reads and parses a simfile in a file system, like ParseFileWith.
+func ParseFSWith(fsys fs.FS, name string, options ParseOptions) (Simfile, error) {
+	if isKSF(name) {
+		return parseKSFNamed(fsys, path.Dir(name), name, options)
+	}
+	data, err := readSimfileFS(fsys, name)
+	return parseNamed(data, err, name, options)
+}
+
+// readSimfileFS reads a file in a file system when ParseFile supports its format.
+func readSimfileFS(fsys fs.FS, name string) ([]byte, error) {
+	return readSimfile(name, func(name string) ([]byte, error) { return fs.ReadFile(fsys, name) })
+}
+
+// parseNamed parses the data read from smPath with the parser of its format, naming its pack
+// from the path.
+func parseNamed(data []byte, err error, smPath string, options ParseOptions) (Simfile, error) {
+	if err != nil {
+		return Simfile{}, err
+	}
+	ext := strings.ToLower(path.Ext(smPath))
+	sim, err := formatParsers[ext](data, options)
+	if err != nil {
+		return Simfile{}, err
+	}
+	sim.SongPack = PackName(smPath)
+	if ext == ".ucs" {
+		// UCS files have no title tag and are named after the song instead.
+		sim.Header.Title = strings.TrimSuffix(path.Base(smPath), path.Ext(smPath))
+	}
+	return sim, nil
+}
+
+// parseKSFNamed parses the .ksf files of a song folder with ParseKSFWith, naming its pack from
+// the path of one of them.
+func parseKSFNamed(fsys fs.FS, dir string, ksfPath string, options ParseOptions) (Simfile, error) {
+	sim, err := ParseKSFWith(fsys, dir, options)
+	if err != nil {
+		return Simfile{}, err
+	}
+	sim.SongPack = PackName(ksfPath)
+	return sim, nil
+}
+
+// WriteJSON serializes parsed Simfile data as JSON to <jsonPath>/<Title>.json.
+//
+// The title is sanitized for use as a file name. Use a JSONWriter for other names and to
+// number songs sharing a title.
 func WriteJSON(sim Simfile, jsonPath string) error {
-	simJSON, err := json.Marshal(sim)
-	CheckError(err)
-	outputName := fmt.Sprintf("%s/%s.json", jsonPath, sim.Header.Title)
-	ioutil.WriteFile(outputName, simJSON, 0644)
+	_, err := NewJSONWriter(jsonPath).Write(sim, "")
 	return err
 }
+
+// ReadJSON reads a simfile written by WriteJSON.
+func ReadJSON(jsonPath string) (Simfile, error) {
+	data, err := ioutil.ReadFile(jsonPath)
+	if err != nil {
+		return Simfile{}, err
+	}
+	return ParseJSON(data)
+}
+
+// ParseJSON decodes a simfile written by WriteJSON, migrating older schema versions with
+// MigrateJSON.
+//
+// The analysis and feet of dance-single charts are computed again from the notes, so charts
+// edited as JSON are analyzed as they are now.
+func ParseJSON(data []byte) (sim Simfile, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			sim, err = Simfile{}, fmt.Errorf("Schema Error: %v", r)
+		}
+	}()
+
+	data, err = MigrateJSON(data)
+	if err != nil {
+		return Simfile{}, err
+	}
+	if err := json.Unmarshal(data, &sim); err != nil {
+		return Simfile{}, fmt.Errorf("Schema Error: %v", err)
+	}
+
+	for c, chart := range sim.Charts {
+		if chart.Type != "dance-single" || len(chart.Notes) == 0 {
+			continue
+		}
+		for m, measure := range chart.Notes {
+			if measure.MeasureNumber != m {
+				return Simfile{}, fmt.Errorf("Schema Error: chart %d measure %d has measure_nbr %d", c, m, measure.MeasureNumber)
+			}
+			if measure.Quantization == 0 && len(measure.Steps) == 0 {
+				// A note block ending in "," has an empty last measure.
+				continue
+			}
+			if measure.Quantization <= 0 || measure.Quantization != len(measure.Steps) {
+				return Simfile{}, fmt.Errorf("Schema Error: chart %d measure %d has %d steps for quantization %d", c, m, len(measure.Steps), measure.Quantization)
+			}
+		}
+		sim.Charts[c].Analysis = Analyze(chart, sim.Header)
+	}
+	return sim, nil
+}
@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"fmt"
+	"reflect"
 	"testing"
+	"testing/fstest"
 
 	"github.com/spf13/afero"
 )
@@ -42,3 +45,148 @@ func TestWriteJson(t *testing.T) {
 		t.Error("JSON write failed.")
 	}
 }
+
+func TestParse(t *testing.T) {
+	sim, err := Parse([]byte("#TITLE:Song Title;\n#NOTES:dance-single:::16:0,0,0,0,0:1000,0100;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim.Header.Title != "Song Title" || sim.Charts[0].Meter != 16 {
+		t.Error("Parse did not parse the simfile.")
+	}
+
+	if _, err := Parse([]byte("#NOTES:dance-single:::meter:;")); err == nil {
+		t.Error("Parse did not return an error for a malformed chart.")
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	sim, err := ParseFile("../testdata/sharpnelstreamz/bluearmy/bluearmy.sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim.SongPack != "sharpnelstreamz" || sim.Header.Title != "Blue Army" {
+		t.Error("ParseFile did not parse the simfile.")
+	}
+
+	if _, err := ParseFile("../testdata/README.md"); err == nil {
+		t.Error("ParseFile did not return an error for a non .sm file.")
+	}
+}
+
+func TestParseWithHeaderOnly(t *testing.T) {
+	data, _ := ReadSM("../testdata/sharpnelstreamz/bluearmy/bluearmy.sm")
+	full, _ := Parse(data)
+	sim, err := ParseWith(data, ParseOptions{HeaderOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chart := sim.Charts[0]
+	if chart.Type != "dance-single" || chart.Difficulty != "Challenge" || chart.Meter != 16 {
+		t.Error("Chart metadata not parsed.")
+	}
+	if chart.Notes != nil || chart.RawData == "" {
+		t.Error("Header only parse decoded the note data.")
+	}
+
+	chart = DecodeNotes(chart, sim.Header)
+	if chart.RawData != "" || len(chart.Notes) != len(full.Charts[0].Notes) {
+		t.Error("DecodeNotes did not decode the note data.")
+	}
+	if chart.Analysis.Density.PeakNPS != full.Charts[0].Analysis.Density.PeakNPS {
+		t.Error("DecodeNotes did not analyze the chart.")
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	data, _ := ReadSM("../testdata/sharpnelstreamz/bluearmy/bluearmy.sm")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Parse(data)
+	}
+}
+
+func BenchmarkParseHeaderOnly(b *testing.B) {
+	data, _ := ReadSM("../testdata/sharpnelstreamz/bluearmy/bluearmy.sm")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ParseWith(data, ParseOptions{HeaderOnly: true})
+	}
+}
+
+func TestParseFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"pack/song/song.sm":  &fstest.MapFile{Data: []byte("#TITLE:Song Title;")},
+		"pack/song/song.ogg": &fstest.MapFile{Data: []byte("")},
+	}
+
+	sim, err := ParseFS(fsys, "pack/song/song.sm")
+	if err != nil || sim.Header.Title != "Song Title" || sim.SongPack != "pack" {
+		t.Error("ParseFS did not parse the simfile.")
+	}
+	if _, err := ParseFS(fsys, "pack/song/song.ogg"); err == nil {
+		t.Error("ParseFS did not return an error for a non .sm file.")
+	}
+	if _, err := ParseFS(fsys, "pack/missing/missing.sm"); err == nil {
+		t.Error("ParseFS did not return an error for a missing file.")
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	sim, err := ParseFile("../testdata/sharpnelstreamz/bluearmy/bluearmy.sm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var Fs = afero.NewOsFs()
+	outputDir, _ := afero.TempDir(Fs, "", "_")
+	defer Fs.RemoveAll(outputDir)
+	if err := WriteJSON(sim, outputDir); err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := ReadJSON(outputDir + "/Blue Army.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, sim) {
+		t.Error("ReadJSON did not reconstruct the written Simfile.")
+	}
+	if _, err := ReadJSON(outputDir + "/missing.json"); err == nil {
+		t.Error("Expected an error for a missing file.")
+	}
+
+	// A note block ending in "," has an empty last measure with quantization 0.
+	trailing, _ := Parse([]byte("#TITLE:Trailing;#BPMS:0=120;#NOTES:dance-single:::1:0,0,0,0,0:1000\n,\n;"))
+	if err := WriteJSON(trailing, outputDir); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := ReadJSON(outputDir + "/Trailing.json"); err != nil || !reflect.DeepEqual(read, trailing) {
+		errorMsg := fmt.Sprintf("Expected the chart with an empty last measure to round trip, received: %v", err)
+		t.Error(errorMsg)
+	}
+}
+
+func TestTableParseJSON(t *testing.T) {
+	var tests = []struct {
+		data  string
+		valid bool
+	}{
+		{`{"header":{"title":"Old"},"charts":[{"type":"dance-single","notes":[{"measure_nbr":0,"quantization":2,"steps":[{"l":"1","d":"0","u":"0","r":"0"},{"beat":2,"l":"0","d":"0","u":"0","r":"1"}]}]}]}`, true},
+		{`{"schema_version":1,"charts":[{"type":"dance-single","notes":[{"measure_nbr":1,"quantization":1,"steps":[{}]}]}]}`, false},
+		{`{"schema_version":1,"charts":[{"type":"dance-single","notes":[{"measure_nbr":0,"quantization":4,"steps":[{}]}]}]}`, false},
+		{`{"schema_version":1,"charts":"none"}`, false},
+		{`{"schema_version":2}`, false},
+	}
+	for _, test := range tests {
+		sim, err := ParseJSON([]byte(test.data))
+		if (err == nil) != test.valid {
+			errorMsg := fmt.Sprintf("Expected valid=%t for %s, received: %v", test.valid, test.data, err)
+			t.Error(errorMsg)
+		}
+		if err == nil && (sim.SchemaVersion != SchemaVersion || sim.Charts[0].Notes[0].Steps[1].Feet == "") {
+			errorMsg := fmt.Sprintf("Expected a migrated and analyzed Simfile for %s.", test.data)
+			t.Error(errorMsg)
+		}
+	}
+}
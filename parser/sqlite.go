@@ -0,0 +1,337 @@
+package parser
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"path"
+)
+
+// sqliteSchema creates the tables written by ExportSQLite. Beats are quarter notes and times
+// are in seconds, as in the JSON output.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS packs (
+	id INTEGER PRIMARY KEY,
+	root TEXT NOT NULL,
+	name TEXT NOT NULL,
+	path TEXT NOT NULL UNIQUE
+);
+CREATE TABLE IF NOT EXISTS songs (
+	id INTEGER PRIMARY KEY,
+	pack_id INTEGER NOT NULL REFERENCES packs(id),
+	path TEXT NOT NULL UNIQUE,
+	hash TEXT NOT NULL,
+	title TEXT NOT NULL,
+	subtitle TEXT NOT NULL,
+	artist TEXT NOT NULL,
+	title_translit TEXT NOT NULL,
+	subtitle_translit TEXT NOT NULL,
+	artist_translit TEXT NOT NULL,
+	genre TEXT NOT NULL,
+	credit TEXT NOT NULL,
+	music TEXT NOT NULL,
+	banner TEXT NOT NULL,
+	background TEXT NOT NULL,
+	offset_seconds REAL NOT NULL,
+	sample_start REAL NOT NULL,
+	sample_length REAL NOT NULL,
+	bpm_min REAL NOT NULL,
+	bpm_max REAL NOT NULL,
+	length_seconds REAL NOT NULL
+);
+CREATE TABLE IF NOT EXISTS timing_segments (
+	song_id INTEGER NOT NULL REFERENCES songs(id),
+	kind TEXT NOT NULL,
+	beat REAL NOT NULL,
+	seconds REAL NOT NULL,
+	value REAL NOT NULL
+);
+CREATE INDEX IF NOT EXISTS timing_segments_song ON timing_segments(song_id);
+CREATE TABLE IF NOT EXISTS charts (
+	id INTEGER PRIMARY KEY,
+	song_id INTEGER NOT NULL REFERENCES songs(id),
+	chart_index INTEGER NOT NULL,
+	type TEXT NOT NULL,
+	difficulty TEXT NOT NULL,
+	description TEXT NOT NULL,
+	meter INTEGER NOT NULL,
+	groovestats_hash TEXT NOT NULL,
+	chart_key TEXT NOT NULL,
+	UNIQUE (song_id, chart_index)
+);
+CREATE TABLE IF NOT EXISTS chart_stats (
+	chart_id INTEGER PRIMARY KEY REFERENCES charts(id),
+	notes INTEGER NOT NULL,
+	jumps INTEGER NOT NULL,
+	hands INTEGER NOT NULL,
+	holds INTEGER NOT NULL,
+	rolls INTEGER NOT NULL,
+	mines INTEGER NOT NULL,
+	measures INTEGER NOT NULL,
+	stream_measures INTEGER NOT NULL,
+	stream_ratio REAL NOT NULL,
+	length_seconds REAL NOT NULL,
+	peak_nps REAL NOT NULL,
+	crossovers INTEGER NOT NULL,
+	footswitches INTEGER NOT NULL,
+	doublesteps INTEGER NOT NULL,
+	jacks INTEGER NOT NULL,
+	brackets INTEGER NOT NULL,
+	estimate_overall REAL NOT NULL,
+	estimate_stream REAL NOT NULL,
+	estimate_jumpstream REAL NOT NULL,
+	estimate_handstream REAL NOT NULL,
+	estimate_stamina REAL NOT NULL,
+	estimate_jackspeed REAL NOT NULL,
+	estimate_chordjack REAL NOT NULL,
+	estimate_technical REAL NOT NULL
+);
+CREATE TABLE IF NOT EXISTS chart_patterns (
+	chart_id INTEGER NOT NULL REFERENCES charts(id),
+	kind TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	PRIMARY KEY (chart_id, kind)
+);
+`
+
+// SQLiteExport counts the songs changed by ExportSQLite.
+type SQLiteExport struct {
+	Inserted  int
+	Updated   int
+	Unchanged int
+	Deleted   int
+}
+
+// ExportSQLite writes the packs, songs, timing segments, charts and chart statistics of a
+// Library into a SQLite database, creating the tables if needed.
+//
+// The database is opened by the caller with a SQLite driver, such as modernc.org/sqlite.
+// Exporting a rescan of the same root updates songs whose simfile changed, leaves unchanged
+// songs alone, and deletes songs that are gone. Songs that failed to parse are kept as they
+// were. Only parsed charts are written, so scan with notes decoded for chart statistics.
+func ExportSQLite(ctx context.Context, db *sql.DB, library Library) (SQLiteExport, error) {
+	export := SQLiteExport{}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return export, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, sqliteSchema); err != nil {
+		return export, err
+	}
+
+	seen := map[string]bool{}
+	for _, songErr := range library.Errors {
+		seen[songErr.Path] = true
+	}
+	for _, pack := range library.Packs {
+		packID, err := upsertPack(ctx, tx, library.Root, pack)
+		if err != nil {
+			return export, err
+		}
+		for _, song := range pack.Songs {
+			seen[song.Path] = true
+			change, err := upsertSong(ctx, tx, packID, song)
+			if err != nil {
+				return export, err
+			}
+			switch change {
+			case "inserted":
+				export.Inserted++
+			case "updated":
+				export.Updated++
+			default:
+				export.Unchanged++
+			}
+		}
+	}
+
+	// Delete the songs of this root that were not scanned.
+	rows, err := tx.QueryContext(ctx, `SELECT songs.id, songs.path FROM songs JOIN packs ON packs.id = songs.pack_id WHERE packs.root = ?`, library.Root)
+	if err != nil {
+		return export, err
+	}
+	gone := []int64{}
+	for rows.Next() {
+		var id int64
+		var songPath string
+		if err := rows.Scan(&id, &songPath); err != nil {
+			rows.Close()
+			return export, err
+		}
+		if !seen[songPath] && !seen[path.Dir(songPath)] {
+			gone = append(gone, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return export, err
+	}
+	for _, id := range gone {
+		if err := deleteSong(ctx, tx, id, true); err != nil {
+			return export, err
+		}
+		export.Deleted++
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM packs WHERE root = ? AND id NOT IN (SELECT pack_id FROM songs)`, library.Root); err != nil {
+		return export, err
+	}
+	return export, tx.Commit()
+}
+
+// upsertPack inserts or renames a pack and returns its id.
+func upsertPack(ctx context.Context, tx *sql.Tx, root string, pack Pack) (int64, error) {
+	_, err := tx.ExecContext(ctx, `INSERT INTO packs (root, name, path) VALUES (?, ?, ?)
+		ON CONFLICT (path) DO UPDATE SET root = excluded.root, name = excluded.name`, root, pack.Name, pack.Path)
+	if err != nil {
+		return 0, err
+	}
+	var id int64
+	err = tx.QueryRowContext(ctx, `SELECT id FROM packs WHERE path = ?`, pack.Path).Scan(&id)
+	return id, err
+}
+
+// upsertSong writes a song unless it is unchanged, returning "inserted", "updated" or
+// "unchanged".
+func upsertSong(ctx context.Context, tx *sql.Tx, packID int64, song Song) (string, error) {
+	simJSON, err := json.Marshal(song.Simfile)
+	if err != nil {
+		return "", err
+	}
+	sum := sha1.Sum(simJSON)
+	hash := hex.EncodeToString(sum[:])
+
+	change := "inserted"
+	var id int64
+	var oldHash string
+	err = tx.QueryRowContext(ctx, `SELECT id, hash FROM songs WHERE path = ?`, song.Path).Scan(&id, &oldHash)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return "", err
+	case oldHash == hash:
+		_, err := tx.ExecContext(ctx, `UPDATE songs SET pack_id = ? WHERE id = ?`, packID, id)
+		return "unchanged", err
+	default:
+		change = "updated"
+		if err := deleteSong(ctx, tx, id, false); err != nil {
+			return "", err
+		}
+	}
+
+	sim := song.Simfile
+	header := sim.Header
+	timing := NewTiming(header)
+	summaries := make([]ChartSummary, len(sim.Charts))
+	length := 0.0
+	for i, chart := range sim.Charts {
+		summaries[i] = Summarize(chart, header)
+		length = max(length, summaries[i].LastSeconds)
+	}
+	low, high := BPMRange(header.BPMs)
+
+	values := []interface{}{packID, song.Path, hash, header.Title, header.Subtitle, header.Artist,
+		header.TitleTranslit, header.SubtitleTranslit, header.ArtistTranslit, header.Genre, header.Credit,
+		header.Music, header.Banner, header.Background, header.Offset, header.SampleStart, header.SampleLength,
+		low, high, length}
+	if change == "inserted" {
+		result, err := tx.ExecContext(ctx, `INSERT INTO songs (pack_id, path, hash, title, subtitle, artist,
+			title_translit, subtitle_translit, artist_translit, genre, credit, music, banner, background,
+			offset_seconds, sample_start, sample_length, bpm_min, bpm_max, length_seconds)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, values...)
+		if err != nil {
+			return "", err
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return "", err
+		}
+	} else {
+		_, err := tx.ExecContext(ctx, `UPDATE songs SET pack_id = ?, path = ?, hash = ?, title = ?, subtitle = ?,
+			artist = ?, title_translit = ?, subtitle_translit = ?, artist_translit = ?, genre = ?, credit = ?,
+			music = ?, banner = ?, background = ?, offset_seconds = ?, sample_start = ?, sample_length = ?,
+			bpm_min = ?, bpm_max = ?, length_seconds = ? WHERE id = ?`, append(values, id)...)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	segments := [][]BeatChange{header.BPMs, header.Stops, header.Delays}
+	for s, kind := range []string{"bpm", "stop", "delay"} {
+		for _, c := range segments[s] {
+			_, err := tx.ExecContext(ctx, `INSERT INTO timing_segments (song_id, kind, beat, seconds, value) VALUES (?, ?, ?, ?, ?)`,
+				id, kind, c.Beat, timing.Seconds(c.Beat), c.Value)
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	for i, chart := range sim.Charts {
+		if chart.Type == "" {
+			continue
+		}
+		if err := insertChart(ctx, tx, id, i, chart, header, summaries[i]); err != nil {
+			return "", err
+		}
+	}
+	return change, nil
+}
+
+// insertChart writes a chart with its statistics and pattern counts.
+func insertChart(ctx context.Context, tx *sql.Tx, songID int64, index int, chart Chart, header Header, summary ChartSummary) error {
+	result, err := tx.ExecContext(ctx, `INSERT INTO charts (song_id, chart_index, type, difficulty, description, meter,
+		groovestats_hash, chart_key) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		songID, index, chart.Type, chart.Difficulty, chart.Description, chart.Meter,
+		GrooveStatsHash(chart, header), ChartKey(chart, header))
+	if err != nil {
+		return err
+	}
+	chartID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	analysis := chart.Analysis
+	parity, difficulty := analysis.Parity, analysis.Difficulty
+	_, err = tx.ExecContext(ctx, `INSERT INTO chart_stats (chart_id, notes, jumps, hands, holds, rolls, mines,
+		measures, stream_measures, stream_ratio, length_seconds, peak_nps, crossovers, footswitches, doublesteps,
+		jacks, brackets, estimate_overall, estimate_stream, estimate_jumpstream, estimate_handstream,
+		estimate_stamina, estimate_jackspeed, estimate_chordjack, estimate_technical)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		chartID, summary.Notes, summary.Jumps, summary.Hands, summary.Holds, summary.Rolls, summary.Mines,
+		summary.Measures, summary.StreamMeasures, summary.StreamRatio, summary.LastSeconds, analysis.Density.PeakNPS,
+		parity.Crossovers, parity.Footswitches, parity.Doublesteps, parity.Jacks, parity.Brackets,
+		difficulty.Overall, difficulty.Stream, difficulty.Jumpstream, difficulty.Handstream,
+		difficulty.Stamina, difficulty.Jackspeed, difficulty.Chordjack, difficulty.Technical)
+	if err != nil {
+		return err
+	}
+	for kind, count := range analysis.Patterns.Counts {
+		_, err := tx.ExecContext(ctx, `INSERT INTO chart_patterns (chart_id, kind, count) VALUES (?, ?, ?)`, chartID, kind, count)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSong deletes the timing segments and charts of a song, and the song itself when
+// withSong is set.
+func deleteSong(ctx context.Context, tx *sql.Tx, songID int64, withSong bool) error {
+	statements := []string{
+		`DELETE FROM chart_patterns WHERE chart_id IN (SELECT id FROM charts WHERE song_id = ?)`,
+		`DELETE FROM chart_stats WHERE chart_id IN (SELECT id FROM charts WHERE song_id = ?)`,
+		`DELETE FROM charts WHERE song_id = ?`,
+		`DELETE FROM timing_segments WHERE song_id = ?`,
+	}
+	if withSong {
+		statements = append(statements, `DELETE FROM songs WHERE id = ?`)
+	}
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement, songID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
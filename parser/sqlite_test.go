@@ -0,0 +1,100 @@
+package parser
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	_ "modernc.org/sqlite"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	var Fs = afero.NewOsFs()
+	dir, _ := afero.TempDir(Fs, "", "sqlite")
+	t.Cleanup(func() { Fs.RemoveAll(dir) })
+	db, err := sql.Open("sqlite", filepath.Join(dir, "library.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestExportSQLite(t *testing.T) {
+	ctx := context.Background()
+	library, err := ScanLibrary(ctx, "../testdata", BatchOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := openTestDB(t)
+
+	export, err := ExportSQLite(ctx, db, library)
+	if err != nil || export != (SQLiteExport{Inserted: 2}) {
+		t.Fatal(fmt.Sprintf("Expected 2 inserted songs, received: %+v (%v)", export, err))
+	}
+
+	var tests = []struct {
+		query string
+		count int
+	}{
+		{`SELECT COUNT(*) FROM packs`, 1},
+		{`SELECT COUNT(*) FROM songs`, 2},
+		{`SELECT COUNT(*) FROM charts`, 6},
+		{`SELECT COUNT(*) FROM chart_stats`, 6},
+		{`SELECT COUNT(*) FROM timing_segments WHERE kind = 'bpm'`, 11},
+		{`SELECT COUNT(*) FROM charts JOIN chart_stats ON chart_id = charts.id JOIN songs ON songs.id = song_id
+			WHERE meter >= 14 AND bpm_max > 170 AND stream_ratio > 0.6`, 2},
+	}
+	for _, test := range tests {
+		count := 0
+		if err := db.QueryRow(test.query).Scan(&count); err != nil || count != test.count {
+			errorMsg := fmt.Sprintf("Expected %d for %s, received: %d (%v)", test.count, test.query, count, err)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestExportSQLiteRescan(t *testing.T) {
+	ctx := context.Background()
+	library, err := ScanLibrary(ctx, "../testdata", BatchOptions{Parse: ParseOptions{HeaderOnly: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := openTestDB(t)
+	ExportSQLite(ctx, db, library)
+
+	export, err := ExportSQLite(ctx, db, library)
+	if err != nil || export != (SQLiteExport{Unchanged: 2}) {
+		errorMsg := fmt.Sprintf("Expected 2 unchanged songs, received: %+v (%v)", export, err)
+		t.Error(errorMsg)
+	}
+
+	pack := &library.Packs[0]
+	pack.Songs[0].Simfile.Header.Title = "Renamed"
+	pack.Songs = pack.Songs[:1]
+	export, err = ExportSQLite(ctx, db, library)
+	if err != nil || export != (SQLiteExport{Updated: 1, Deleted: 1}) {
+		errorMsg := fmt.Sprintf("Expected 1 updated and 1 deleted song, received: %+v (%v)", export, err)
+		t.Error(errorMsg)
+	}
+
+	title, charts := "", 0
+	db.QueryRow(`SELECT title, (SELECT COUNT(*) FROM charts) FROM songs`).Scan(&title, &charts)
+	if title != "Renamed" || charts != 3 {
+		errorMsg := fmt.Sprintf("Expected the renamed song with 3 charts, received: %s with %d", title, charts)
+		t.Error(errorMsg)
+	}
+
+	library.Packs = nil
+	if export, _ := ExportSQLite(ctx, db, library); export.Deleted != 1 {
+		t.Error("Expected the last song to be deleted.")
+	}
+	packs := -1
+	db.QueryRow(`SELECT COUNT(*) FROM packs`).Scan(&packs)
+	if packs != 0 {
+		t.Error("Expected the empty pack to be deleted.")
+	}
+}
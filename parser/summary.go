@@ -0,0 +1,91 @@
+package parser
+
+// streamMeasureNotes is the number of notes that makes a measure count as stream, as in
+// 16th-note stream.
+const streamMeasureNotes = 16
+
+// ChartSummary contains note counts and timing of a chart, for exports and statistics.
+type ChartSummary struct {
+	// Notes counts rows with notes to hit, so a jump is one note.
+	Notes int
+	// Jumps counts rows with exactly 2 notes to hit; Hands counts rows with 3 or more.
+	Jumps int
+	Hands int
+	Holds int
+	Rolls int
+	Mines int
+	// Measures is the number of measures from the first to the last note.
+	Measures int
+	// StreamMeasures counts measures with at least 16 notes, and StreamRatio is their share
+	// of Measures.
+	StreamMeasures int
+	StreamRatio    float64
+	// FirstSeconds and LastSeconds are the song times of the first and last notes.
+	FirstSeconds float64
+	LastSeconds  float64
+}
+
+// Summarize counts the notes of a chart.
+func Summarize(chart Chart, header Header) ChartSummary {
+	summary := ChartSummary{}
+	perMeasure := make([]int, len(chart.Notes))
+	first, last := -1, -1
+	for row := range Rows(chart, header, NonEmpty) {
+		for column := 0; column < 16; column++ {
+			switch row.Notes.Kind(column) {
+			case NoteHoldHead:
+				summary.Holds++
+			case NoteRollHead:
+				summary.Rolls++
+			case NoteMine:
+				summary.Mines++
+			}
+		}
+
+		hits := row.Notes.Hits()
+		switch {
+		case hits == 0:
+			continue
+		case hits == 2:
+			summary.Jumps++
+		case hits >= 3:
+			summary.Hands++
+		}
+		summary.Notes++
+		perMeasure[row.Measure]++
+		if first < 0 {
+			first = row.Measure
+			summary.FirstSeconds = row.Seconds
+		}
+		last = row.Measure
+		summary.LastSeconds = row.Seconds
+	}
+
+	if first < 0 {
+		return summary
+	}
+	summary.Measures = last - first + 1
+	for _, notes := range perMeasure[first : last+1] {
+		if notes >= streamMeasureNotes {
+			summary.StreamMeasures++
+		}
+	}
+	summary.StreamRatio = float64(summary.StreamMeasures) / float64(summary.Measures)
+	return summary
+}
+
+// BPMRange returns the lowest and highest BPM of a song, ignoring negative BPMs used for
+// warps. It returns 0, 0 when there are none.
+func BPMRange(bpms []BeatChange) (float64, float64) {
+	low, high := 0.0, 0.0
+	for _, bpm := range bpms {
+		if bpm.Value <= 0 {
+			continue
+		}
+		if low == 0 || bpm.Value < low {
+			low = bpm.Value
+		}
+		high = max(high, bpm.Value)
+	}
+	return low, high
+}
@@ -0,0 +1,46 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	header := Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 120}}}
+	stream := strings.Repeat("1000", 16)
+	chart := Chart{Notes: noteData("0000,1100,2000,3000," + stream + ",11100000M0004000,0000,0000")}
+	summary := Summarize(chart, header)
+
+	expected := ChartSummary{
+		Notes: 20, Jumps: 1, Hands: 1, Holds: 1, Rolls: 1, Mines: 1,
+		Measures: 5, StreamMeasures: 1, StreamRatio: 0.2,
+		FirstSeconds: 2, LastSeconds: 11.5,
+	}
+	if summary != expected {
+		errorMsg := fmt.Sprintf("Expected %+v, received: %+v", expected, summary)
+		t.Error(errorMsg)
+	}
+
+	if (Summarize(Chart{}, header) != ChartSummary{}) {
+		t.Error("Expected an empty summary for a chart without notes.")
+	}
+}
+
+func TestTableBPMRange(t *testing.T) {
+	var tests = []struct {
+		bpms []BeatChange
+		low  float64
+		high float64
+	}{
+		{nil, 0, 0},
+		{[]BeatChange{{0, 150}}, 150, 150},
+		{[]BeatChange{{0, 180}, {16, 90}, {32, -180}, {33, 200}}, 90, 200},
+	}
+	for _, test := range tests {
+		if low, high := BPMRange(test.bpms); low != test.low || high != test.high {
+			errorMsg := fmt.Sprintf("Expected %g-%g, received: %g-%g", test.low, test.high, low, high)
+			t.Error(errorMsg)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package parser
+
+import "iter"
+
+// ChartRow is a row of the charts table written by CSVWriter and ParquetWriter.
+type ChartRow struct {
+	ChartID        int64   `parquet:"chart_id"`
+	Path           string  `parquet:"path"`
+	Pack           string  `parquet:"pack"`
+	Title          string  `parquet:"title"`
+	Subtitle       string  `parquet:"subtitle"`
+	Artist         string  `parquet:"artist"`
+	ChartIndex     int32   `parquet:"chart_index"`
+	Type           string  `parquet:"type"`
+	Difficulty     string  `parquet:"difficulty"`
+	Description    string  `parquet:"description"`
+	Meter          int32   `parquet:"meter"`
+	BPMMin         float64 `parquet:"bpm_min"`
+	BPMMax         float64 `parquet:"bpm_max"`
+	LengthSeconds  float64 `parquet:"length_seconds"`
+	Notes          int32   `parquet:"notes"`
+	Jumps          int32   `parquet:"jumps"`
+	Hands          int32   `parquet:"hands"`
+	Holds          int32   `parquet:"holds"`
+	Rolls          int32   `parquet:"rolls"`
+	Mines          int32   `parquet:"mines"`
+	StreamMeasures int32   `parquet:"stream_measures"`
+	StreamRatio    float64 `parquet:"stream_ratio"`
+	PeakNPS        float64 `parquet:"peak_nps"`
+	Crossovers     int32   `parquet:"crossovers"`
+	Footswitches   int32   `parquet:"footswitches"`
+	Jacks          int32   `parquet:"jacks"`
+	Estimate       float64 `parquet:"estimate"`
+}
+
+// NoteRow is a row of the notes table written by CSVWriter and ParquetWriter, with a row per
+// note joined to the charts table on ChartID.
+type NoteRow struct {
+	ChartID int64   `parquet:"chart_id"`
+	Measure int32   `parquet:"measure"`
+	Beat    float64 `parquet:"beat"`
+	Seconds float64 `parquet:"seconds"`
+	Snap    int32   `parquet:"snap"`
+	Column  int32   `parquet:"column"`
+	Panel   string  `parquet:"panel"`
+	Note    string  `parquet:"note"`
+	Foot    string  `parquet:"foot"`
+}
+
+// panelNames are the dance-single panels in column order.
+var panelNames = []string{"left", "down", "up", "right"}
+
+// pumpPanelNames are the pump-single panels in column order. pump-double charts have them twice,
+// prefixed with p1_ and p2_.
+var pumpPanelNames = []string{"down_left", "up_left", "center", "up_right", "down_right"}
+
+// chartPanels returns the names of the columns of a chart, in column order.
+func chartPanels(chart Chart) []string {
+	switch chart.Type {
+	case "pump-single":
+		return pumpPanelNames
+	case "pump-double":
+		panels := []string{}
+		for _, pad := range []string{"p1_", "p2_"} {
+			for _, panel := range pumpPanelNames {
+				panels = append(panels, pad+panel)
+			}
+		}
+		return panels
+	}
+	return panelNames
+}
+
+// newChartRow builds the charts table row of a chart of a simfile read from source.
+func newChartRow(id int64, sim Simfile, source string, index int, chart Chart) ChartRow {
+	header := sim.Header
+	low, high := BPMRange(header.BPMs)
+	summary := Summarize(chart, header)
+	analysis := chart.Analysis
+	return ChartRow{
+		ChartID:        id,
+		Path:           source,
+		Pack:           sim.SongPack,
+		Title:          header.Title,
+		Subtitle:       header.Subtitle,
+		Artist:         header.Artist,
+		ChartIndex:     int32(index),
+		Type:           chart.Type,
+		Difficulty:     chart.Difficulty,
+		Description:    chart.Description,
+		Meter:          int32(chart.Meter),
+		BPMMin:         low,
+		BPMMax:         high,
+		LengthSeconds:  summary.LastSeconds,
+		Notes:          int32(summary.Notes),
+		Jumps:          int32(summary.Jumps),
+		Hands:          int32(summary.Hands),
+		Holds:          int32(summary.Holds),
+		Rolls:          int32(summary.Rolls),
+		Mines:          int32(summary.Mines),
+		StreamMeasures: int32(summary.StreamMeasures),
+		StreamRatio:    summary.StreamRatio,
+		PeakNPS:        analysis.Density.PeakNPS,
+		Crossovers:     int32(analysis.Parity.Crossovers),
+		Footswitches:   int32(analysis.Parity.Footswitches),
+		Jacks:          int32(analysis.Parity.Jacks),
+		Estimate:       analysis.Difficulty.Overall,
+	}
+}
+
+// noteTableRows returns an iterator over the notes table rows of a chart in time order.
+func noteTableRows(id int64, chart Chart, header Header) iter.Seq[NoteRow] {
+	return func(yield func(NoteRow) bool) {
+		panels := chartPanels(chart)
+		for row := range Rows(chart, header, NonEmpty) {
+			for column, panel := range panels {
+				kind := row.Notes.Kind(column)
+				if kind == NoteEmpty {
+					continue
+				}
+				foot := ""
+				if f := row.Notes.Foot(column); f != 0 {
+					foot = string(f)
+				}
+				note := NoteRow{
+					ChartID: id,
+					Measure: int32(row.Measure),
+					Beat:    row.Beat,
+					Seconds: row.Seconds,
+					Snap:    int32(row.Snap),
+					Column:  int32(column),
+					Panel:   panel,
+					Note:    kind.Name(),
+					Foot:    foot,
+				}
+				if !yield(note) {
+					return
+				}
+			}
+		}
+	}
+}
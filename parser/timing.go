@@ -0,0 +1,79 @@
+package parser
+
+import "sort"
+
+// Timing converts beats to song time using the Header BPMs, Stops, Delays and Offset.
+type Timing struct {
+	offset float64
+	bpms   []BeatChange
+	stops  []BeatChange
+	delays []BeatChange
+}
+
+// NewTiming builds the Timing for a Header.
+func NewTiming(header Header) Timing {
+	bpms := append([]BeatChange(nil), header.BPMs...)
+	sort.SliceStable(bpms, func(i, j int) bool { return bpms[i].Beat < bpms[j].Beat })
+	stops := append([]BeatChange(nil), header.Stops...)
+	sort.SliceStable(stops, func(i, j int) bool { return stops[i].Beat < stops[j].Beat })
+	delays := append([]BeatChange(nil), header.Delays...)
+	sort.SliceStable(delays, func(i, j int) bool { return delays[i].Beat < delays[j].Beat })
+	return Timing{offset: header.Offset, bpms: bpms, stops: stops, delays: delays}
+}
+
+// Seconds returns the song time of a beat.
+//
+// Stops on the same beat are not included, since the note is hit before the stop. Delays on the
+// same beat are, since the note is hit after the delay.
+func (t Timing) Seconds(beat float64) float64 {
+	seconds := -t.offset
+	if len(t.bpms) == 0 {
+		return seconds
+	}
+
+	for i, bpm := range t.bpms {
+		start := bpm.Beat
+		if i == 0 {
+			start = 0
+		}
+		if start >= beat {
+			break
+		}
+		end := beat
+		if i+1 < len(t.bpms) && t.bpms[i+1].Beat < beat {
+			end = t.bpms[i+1].Beat
+		}
+		if bpm.Value > 0 {
+			seconds += (end - start) * 60 / bpm.Value
+		}
+	}
+	if beat < 0 && t.bpms[0].Value > 0 {
+		seconds += beat * 60 / t.bpms[0].Value
+	}
+
+	for _, stop := range t.stops {
+		if stop.Beat >= beat {
+			break
+		}
+		seconds += stop.Value
+	}
+	for _, delay := range t.delays {
+		if delay.Beat > beat {
+			break
+		}
+		seconds += delay.Value
+	}
+	return seconds
+}
+
+// BPM returns the BPM in effect at a beat.
+func (t Timing) BPM(beat float64) float64 {
+	bpm := 0.0
+	for i, change := range t.bpms {
+		if i > 0 && change.Beat > beat {
+			break
+		}
+		bpm = change.Value
+	}
+	return bpm
+}
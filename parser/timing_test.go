@@ -0,0 +1,89 @@
+package parser
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestTableTimingSeconds(t *testing.T) {
+	header := Header{
+		Offset: -0.5,
+		BPMs:   []BeatChange{BeatChange{Beat: 0, Value: 120}, BeatChange{Beat: 8, Value: 240}},
+		Stops:  []BeatChange{BeatChange{Beat: 4, Value: 1.5}},
+	}
+	timing := NewTiming(header)
+
+	var tests = []struct {
+		beat    float64
+		seconds float64
+	}{
+		{0, 0.5},
+		{2, 1.5},
+		{4, 2.5},
+		{6, 5.0},
+		{8, 6.0},
+		{12, 7.0},
+		{-2, -0.5},
+	}
+
+	for _, test := range tests {
+		if output := timing.Seconds(test.beat); math.Abs(output-test.seconds) > 1e-9 {
+			errorMsg := fmt.Sprintf("Expected %f seconds at beat %f, received: %f", test.seconds, test.beat, output)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestTableTimingDelays(t *testing.T) {
+	header := Header{
+		BPMs:   []BeatChange{BeatChange{Beat: 0, Value: 120}},
+		Stops:  []BeatChange{BeatChange{Beat: 4, Value: 1}},
+		Delays: []BeatChange{BeatChange{Beat: 4, Value: 0.5}},
+	}
+	timing := NewTiming(header)
+
+	var tests = []struct {
+		beat    float64
+		seconds float64
+	}{
+		{2, 1.0},
+		{4, 2.5},
+		{6, 4.5},
+	}
+
+	for _, test := range tests {
+		if output := timing.Seconds(test.beat); math.Abs(output-test.seconds) > 1e-9 {
+			errorMsg := fmt.Sprintf("Expected %f seconds at beat %f, received: %f", test.seconds, test.beat, output)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestTimingSecondsWithoutBPMs(t *testing.T) {
+	timing := NewTiming(Header{Offset: 0.25})
+	if output := timing.Seconds(16); output != -0.25 {
+		t.Error("Timing without BPMs should only apply the offset.")
+	}
+}
+
+func TestTableTimingBPM(t *testing.T) {
+	timing := NewTiming(Header{BPMs: []BeatChange{BeatChange{Beat: 0, Value: 120}, BeatChange{Beat: 8, Value: 240}}})
+	var tests = []struct {
+		beat float64
+		bpm  float64
+	}{
+		{-1, 120},
+		{0, 120},
+		{7.5, 120},
+		{8, 240},
+		{100, 240},
+	}
+
+	for _, test := range tests {
+		if output := timing.BPM(test.beat); output != test.bpm {
+			errorMsg := fmt.Sprintf("Expected %f BPM at beat %f, received: %f", test.bpm, test.beat, output)
+			t.Error(errorMsg)
+		}
+	}
+}
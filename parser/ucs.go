@@ -0,0 +1,293 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ucsModes maps UCS :Mode values to chart types. Performance modes are the routine variants
+// of the same pads.
+var ucsModes = map[string]string{
+	"single":        "pump-single",
+	"double":        "pump-double",
+	"s-performance": "pump-single",
+	"d-performance": "pump-double",
+}
+
+// ucsSplits are the :Split values WriteUCS picks from, the rows per beat that divide the
+// 48 rows per beat of a Row index.
+var ucsSplits = []int{1, 2, 3, 4, 6, 8, 12, 16, 24, 48}
+
+// ucsBlock is the timing of a UCS block, which applies to the rows after it.
+type ucsBlock struct {
+	bpm   float64
+	delay float64
+	split int
+}
+
+// ParseUCS parses a .ucs file into a Simfile with one pump-single or pump-double chart.
+//
+// The :BPM of each block becomes a BPM change and its :Delay, in milliseconds, a delay at
+// the first row of the block. The delay of the first block is the offset. :Beat only groups
+// rows into measures in the editor and does not change the timing. UCS files have no title
+// or difficulty, so the chart is an Edit.
+func ParseUCS(data []byte) (Simfile, error) {
+	return ParseUCSWith(data, ParseOptions{})
+}
+
+// ParseUCSWith parses a .ucs file like ParseUCS. Notes are always decoded, since DecodeNotes
+// only reads .sm note data.
+func ParseUCSWith(data []byte, options ParseOptions) (sim Simfile, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			sim, err = Simfile{}, fmt.Errorf("Parse Error: %v", r)
+		}
+	}()
+
+	sim.SchemaVersion = SchemaVersion
+	chart := Chart{Type: "pump-single", Difficulty: "Edit"}
+	columns := 5
+	block := ucsBlock{split: 4}
+	pending := true
+	held := make([]bool, 10)
+	rows := []Row{}
+	beat := 0.0
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if line[0] == ':' {
+			key, value, _ := strings.Cut(line[1:], "=")
+			switch strings.ToLower(strings.TrimSpace(key)) {
+			case "mode":
+				mode, ok := ucsModes[strings.ToLower(strings.TrimSpace(value))]
+				if !ok {
+					panic(fmt.Sprintf("unknown UCS mode %q", value))
+				}
+				chart.Type = mode
+				if mode == "pump-double" {
+					columns = 10
+				}
+			case "bpm":
+				block.bpm = dwiFloat(value)
+			case "delay":
+				block.delay = dwiFloat(value)
+			case "split":
+				block.split = int(dwiFloat(value))
+				if block.split <= 0 {
+					panic(fmt.Sprintf("invalid :Split %q", value))
+				}
+			}
+			pending = true
+			continue
+		}
+
+		if pending {
+			sim.Header = block.apply(sim.Header, beat, len(sim.Header.BPMs) == 0)
+			pending = false
+		}
+		if len(line) != columns {
+			panic(fmt.Sprintf("line %d has %d columns, expected %d", n+1, len(line), columns))
+		}
+		row := Row{Index: int32(math.Round(beat * RowsPerMeasure / 4))}
+		for column := 0; column < columns; column++ {
+			switch line[column] {
+			case '.':
+			case 'X':
+				row.SetKind(column, NoteTap)
+			case 'M':
+				row.SetKind(column, NoteHoldHead)
+				held[column] = true
+			case 'H', 'W':
+				if !held[column] {
+					panic(fmt.Sprintf("line %d continues a hold that was not started with M", n+1))
+				}
+				if line[column] == 'W' {
+					row.SetKind(column, NoteTail)
+					held[column] = false
+				}
+			default:
+				panic(fmt.Sprintf("unknown UCS cell %q on line %d", line[column], n+1))
+			}
+			if held[column] && line[column] != 'M' && line[column] != 'H' {
+				panic(fmt.Sprintf("line %d interrupts a hold that was not ended with W", n+1))
+			}
+		}
+		if !row.IsEmpty() {
+			rows = append(rows, row)
+		}
+		beat += 1 / float64(block.split)
+	}
+
+	chart.Notes = rowMeasures(rows, int(math.Round(beat*RowsPerMeasure/4)), columns)
+	chart.Analysis = Analyze(chart, sim.Header)
+	sim.Charts = []Chart{chart}
+	return sim, nil
+}
+
+// apply adds the timing of a block starting at a beat to a header. The delay of the first
+// block sets the offset.
+func (b ucsBlock) apply(header Header, beat float64, first bool) Header {
+	if first {
+		header.Offset = -b.delay / 1000
+		header.BPMs = []BeatChange{{Beat: 0, Value: b.bpm}}
+		return header
+	}
+	if b.bpm != header.BPMs[len(header.BPMs)-1].Value {
+		header.BPMs = append(header.BPMs, BeatChange{Beat: beat, Value: b.bpm})
+	}
+	if b.delay != 0 {
+		header.Delays = append(header.Delays, BeatChange{Beat: beat, Value: b.delay / 1000})
+	}
+	return header
+}
+
+// MarshalUCS returns a pump-single or pump-double chart of a simfile as a .ucs file.
+func MarshalUCS(sim Simfile, chart int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := WriteUCS(&buf, sim, chart); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteUCS writes a pump-single or pump-double chart of a simfile as a .ucs file.
+//
+// A block starts at beat 0 and at every BPM change and delay. Stops become delays on the
+// next row with notes, which UCS players reach at the same time. Each block uses the
+// smallest :Split that holds its rows. Roll heads are written as holds and lifts as taps;
+// mines and fakes cannot be written.
+func WriteUCS(w io.Writer, sim Simfile, chart int) error {
+	if chart < 0 || chart >= len(sim.Charts) {
+		return fmt.Errorf("UCS Error: no chart %d", chart)
+	}
+	c := sim.Charts[chart]
+	columns := 0
+	mode := ""
+	switch c.Type {
+	case "pump-single":
+		columns, mode = 5, "Single"
+	case "pump-double":
+		columns, mode = 10, "Double"
+	default:
+		return fmt.Errorf("UCS Error: chart %d is %q, not pump-single or pump-double", chart, c.Type)
+	}
+
+	notes, err := CompactMeasures(c.Notes)
+	if err != nil {
+		return err
+	}
+	rows := notes.Rows
+	end := len(c.Notes) * RowsPerMeasure
+	blocks, delays := ucsBlockStarts(sim.Header, rows, end)
+	timing := NewTiming(sim.Header)
+
+	out := bufio.NewWriter(w)
+	fmt.Fprintf(out, ":Format=1\r\n:Mode=%s\r\n", mode)
+	held := make([]bool, columns)
+	next := 0
+	for b, start := range blocks {
+		stop := end
+		if b+1 < len(blocks) {
+			stop = blocks[b+1]
+		}
+		split := ucsSplit(rows[next:], start, stop)
+		delay := delays[start] * 1000
+		if b == 0 {
+			delay -= sim.Header.Offset * 1000
+		}
+		fmt.Fprintf(out, ":BPM=%s\r\n:Delay=%s\r\n:Beat=4\r\n:Split=%d\r\n",
+			formatFloat(timing.BPM(float64(start)*4/RowsPerMeasure)), formatFloat(delay), split)
+
+		line := make([]byte, columns)
+		for index := start; index < stop; index += RowsPerMeasure / 4 / split {
+			row := Row{}
+			if next < len(rows) && int(rows[next].Index) == index {
+				row = rows[next]
+				next++
+			}
+			for column := range line {
+				line[column] = '.'
+				if held[column] {
+					line[column] = 'H'
+				}
+				switch kind := row.Kind(column); kind {
+				case NoteEmpty:
+				case NoteTap, NoteLift:
+					line[column] = 'X'
+				case NoteHoldHead, NoteRollHead:
+					line[column] = 'M'
+					held[column] = true
+				case NoteTail:
+					line[column] = 'W'
+					held[column] = false
+				default:
+					return fmt.Errorf("UCS Error: %s notes cannot be written", kind.Name())
+				}
+			}
+			out.Write(line)
+			out.WriteString("\r\n")
+		}
+	}
+	return out.Flush()
+}
+
+// ucsBlockStarts returns the sorted Row indices where UCS blocks start, and the delay in
+// seconds at each of them. A stop is moved to the first row with notes after it.
+func ucsBlockStarts(header Header, rows []Row, end int) ([]int, map[int]float64) {
+	starts := map[int]bool{0: true}
+	delays := map[int]float64{}
+	index := func(beat float64) int {
+		return int(math.Round(beat * RowsPerMeasure / 4))
+	}
+	for _, bpm := range header.BPMs {
+		if i := index(bpm.Beat); i > 0 && i < end {
+			starts[i] = true
+		}
+	}
+	for _, delay := range header.Delays {
+		if i := index(delay.Beat); i >= 0 && i < end {
+			starts[i] = true
+			delays[i] += delay.Value
+		}
+	}
+	for _, stop := range header.Stops {
+		i := sort.Search(len(rows), func(r int) bool { return int(rows[r].Index) > index(stop.Beat) })
+		if i < len(rows) {
+			starts[int(rows[i].Index)] = true
+			delays[int(rows[i].Index)] += stop.Value
+		}
+	}
+
+	blocks := []int{}
+	for start := range starts {
+		blocks = append(blocks, start)
+	}
+	sort.Ints(blocks)
+	return blocks, delays
+}
+
+// ucsSplit returns the smallest :Split whose rows fall on every Row of a block from start to
+// stop, and on stop itself.
+func ucsSplit(rows []Row, start int, stop int) int {
+	for _, split := range ucsSplits {
+		step := RowsPerMeasure / 4 / split
+		fits := (stop-start)%step == 0
+		for _, row := range rows {
+			if int(row.Index) >= stop || !fits {
+				break
+			}
+			fits = (int(row.Index)-start)%step == 0
+		}
+		if fits {
+			return split
+		}
+	}
+	return RowsPerMeasure / 4
+}
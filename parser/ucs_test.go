@@ -0,0 +1,138 @@
+package parser
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const testUCS = ":Format=1\r\n:Mode=Single\r\n:BPM=120\r\n:Delay=500\r\n:Beat=4\r\n:Split=2\r\n" +
+	"X....\r\n.M...\r\n.H...\r\n.W..X\r\n" +
+	":BPM=240\r\n:Delay=250\r\n:Beat=4\r\n:Split=4\r\n" +
+	"..X..\r\n.....\r\n...X.\r\n.....\r\n"
+
+func TestParseUCS(t *testing.T) {
+	sim, err := ParseUCS([]byte(testUCS))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := sim.Header
+	if header.Offset != -0.5 {
+		errorMsg := fmt.Sprintf("Expected offset -0.5, received: %f", header.Offset)
+		t.Error(errorMsg)
+	}
+	if bpms := []BeatChange{{0, 120}, {2, 240}}; !reflect.DeepEqual(header.BPMs, bpms) {
+		errorMsg := fmt.Sprintf("Expected BPMs %v, received: %v", bpms, header.BPMs)
+		t.Error(errorMsg)
+	}
+	if delays := []BeatChange{{2, 0.25}}; !reflect.DeepEqual(header.Delays, delays) {
+		errorMsg := fmt.Sprintf("Expected delays %v, received: %v", delays, header.Delays)
+		t.Error(errorMsg)
+	}
+
+	chart := sim.Charts[0]
+	rows := []string{"10000", "02000", "03001", "00100", "00010"}
+	if chart.Type != "pump-single" || !reflect.DeepEqual(chartRows(chart), rows) || chart.Notes[0].Quantization != 8 {
+		errorMsg := fmt.Sprintf("Expected pump-single rows %v, received: %s %v", rows, chart.Type, chartRows(chart))
+		t.Error(errorMsg)
+	}
+	// The first row of the second block is hit after its delay.
+	if seconds := NewTiming(header).Seconds(2); seconds != 1.75 {
+		errorMsg := fmt.Sprintf("Expected the second block at 1.75 seconds, received: %f", seconds)
+		t.Error(errorMsg)
+	}
+}
+
+func TestWriteUCS(t *testing.T) {
+	sim, _ := ParseUCS([]byte(testUCS))
+	data, err := MarshalUCS(sim, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ":Format=1\r\n:Mode=Single\r\n:BPM=120\r\n:Delay=500\r\n:Beat=4\r\n:Split=2\r\n" +
+		"X....\r\n.M...\r\n.H...\r\n.W..X\r\n" +
+		":BPM=240\r\n:Delay=250\r\n:Beat=4\r\n:Split=2\r\n" +
+		"..X..\r\n...X.\r\n.....\r\n.....\r\n"
+	if string(data) != expected {
+		errorMsg := fmt.Sprintf("Expected UCS:\n%s\nreceived:\n%s", expected, data)
+		t.Error(errorMsg)
+	}
+
+	again, err := ParseUCS(data)
+	if err != nil || !reflect.DeepEqual(again.Header, sim.Header) || !reflect.DeepEqual(again.Charts[0].Notes, sim.Charts[0].Notes) {
+		errorMsg := fmt.Sprintf("Expected the written UCS to parse back to the same chart, received: %+v (%v)", again.Header, err)
+		t.Error(errorMsg)
+	}
+}
+
+func TestWriteUCSStops(t *testing.T) {
+	sim, _ := ParseKSF(testKSFFolder(), "pack/song")
+	sim.Header.Stops = []BeatChange{{Beat: 0.5, Value: 0.25}}
+	data, err := MarshalUCS(sim, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := ParseUCS(data)
+
+	// The stop after the hold head is a delay on the next row with notes, the hold tail.
+	before, after := NewTiming(sim.Header), NewTiming(again.Header)
+	for _, beat := range []float64{0, 0.5, 1, 1.25, 2} {
+		if b, a := before.Seconds(beat), after.Seconds(beat); b != a {
+			errorMsg := fmt.Sprintf("Expected %f seconds at beat %f, received: %f", b, beat, a)
+			t.Error(errorMsg)
+		}
+	}
+	if !reflect.DeepEqual(chartRows(again.Charts[0]), chartRows(sim.Charts[0])) {
+		errorMsg := fmt.Sprintf("Expected rows %v, received: %v", chartRows(sim.Charts[0]), chartRows(again.Charts[0]))
+		t.Error(errorMsg)
+	}
+}
+
+func TestWriteUCSErrors(t *testing.T) {
+	sim, _ := Parse([]byte("#NOTES:dance-single:::1:0,0,0,0,0:1000,0100;"))
+	var tests = []struct {
+		sim   Simfile
+		chart int
+	}{
+		{sim, 0},
+		{sim, 1},
+		{Simfile{Charts: []Chart{{Type: "pump-single", Notes: []Measure{{Quantization: 1, Steps: []Step{{Columns: "M0000"}}}}}}}, 0},
+	}
+
+	for _, test := range tests {
+		if _, err := MarshalUCS(test.sim, test.chart); err == nil || !strings.HasPrefix(err.Error(), "UCS Error") {
+			errorMsg := fmt.Sprintf("Expected a UCS error for chart %d, received: %v", test.chart, err)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestParseUCSErrors(t *testing.T) {
+	var tests = []string{
+		":Mode=Triple\n",
+		":Split=0\n",
+		":BPM=120\nX...\n",
+		":BPM=120\n..Z..\n",
+		":BPM=120\n.H...\n",
+		":BPM=120\n.M...\n.X...\n",
+	}
+
+	for _, test := range tests {
+		if _, err := ParseUCS([]byte(test)); err == nil || !strings.HasPrefix(err.Error(), "Parse Error") {
+			errorMsg := fmt.Sprintf("Expected a parse error for %q, received: %v", test, err)
+			t.Error(errorMsg)
+		}
+	}
+}
+
+func TestParseFSUCS(t *testing.T) {
+	fsys := fstest.MapFS{"pack/CS001.ucs": {Data: []byte(testUCS)}}
+	sim, err := ParseFS(fsys, "pack/CS001.ucs")
+	if err != nil || sim.Header.Title != "CS001" || len(sim.Charts) != 1 {
+		errorMsg := fmt.Sprintf("Expected ParseFS to parse the .ucs file named CS001, received: %q (%v)", sim.Header.Title, err)
+		t.Error(errorMsg)
+	}
+}